@@ -5,13 +5,16 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"getNoPSS/pkg"
 	"os"
 	"strings"
 	"time"
 
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // aiAnalysisCmd represents the aiAnalysis command
@@ -19,8 +22,13 @@ var aiAnalysisCmd = &cobra.Command{
 	Use:   "aiAnalysis",
 	Short: "使用AI大模型分析Pod安全性",
 	Long:  `使用OpenAI API对所有Pod进行深度安全分析，并将结果保存到本地文件`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		options := cmd.Flags()
+		failOn, _ := options.GetString("fail-on")
+		policy, err := pkg.ParseFailOn(failOn)
+		if err != nil {
+			return err
+		}
 
 		// 获取配置文件路径
 		configPath, _ := options.GetString("config")
@@ -28,10 +36,9 @@ var aiAnalysisCmd = &cobra.Command{
 		// 加载配置文件
 		config, err := pkg.LoadConfig(configPath)
 		if err != nil {
-			fmt.Printf("❌ 加载配置文件失败: %v\n", err)
-			fmt.Println("\n💡 你可以使用以下命令生成示例配置文件:")
+			fmt.Println("💡 你可以使用以下命令生成示例配置文件:")
 			fmt.Println("./getNoPSS generateConfig")
-			return
+			return fmt.Errorf("加载配置文件失败: %w", err)
 		}
 
 		// 获取输出文件路径和格式
@@ -50,24 +57,39 @@ var aiAnalysisCmd = &cobra.Command{
 		}
 
 		// 创建AI分析器
-		analyzer := pkg.NewAIAnalyzer(config)
-
-		// 获取过滤后的Pod列表
-		pods := pkg.ConnectWithPods(options)
-
-		if len(pods.Items) == 0 {
-			fmt.Println("没有找到符合条件的Pod")
-			return
+		analyzer, err := pkg.NewAIAnalyzer(config)
+		if err != nil {
+			return fmt.Errorf("创建AI分析器失败: %w", err)
 		}
 
-		fmt.Printf("开始AI安全分析，共 %d 个Pod...\n", len(pods.Items))
-		fmt.Printf("使用模型: %s\n", config.OpenAI.Model)
+		var analyses []pkg.AIAnalysis
+		manifests, _ := options.GetStringSlice("manifest")
+		if len(manifests) > 0 {
+			// 离线模式：分析清单文件中的 Pod 定义
+			objects, loadErr := pkg.LoadManifests(manifests, os.Stdin)
+			if loadErr != nil {
+				return fmt.Errorf("读取清单失败: %w", loadErr)
+			}
+			if len(objects) == 0 {
+				return fmt.Errorf("清单中没有找到Pod定义")
+			}
 
-		// 使用AI分析Pod
-		analyses, err := analyzer.AnalyzePods(pods)
+			fmt.Printf("开始AI安全分析，共 %d 个清单对象...\n", len(objects))
+			fmt.Printf("使用模型: %s\n", config.OpenAI.Model)
+			analyses, err = analyzer.AnalyzeManifests(objects)
+		} else {
+			fmt.Printf("使用模型: %s\n", config.OpenAI.Model)
+			analyses, err = analyzeClusters(analyzer, options)
+		}
+		// 失败的分析也要计数，所以在检查错误之前推送
+		if gateway, _ := options.GetString("pushgateway"); gateway != "" {
+			if pushErr := pkg.PushAIMetrics(gateway); pushErr != nil {
+				log.Warn().Err(pushErr).Msg("推送AI分析指标失败")
+			}
+		}
+		partial, err := splitPartial(err)
 		if err != nil {
-			fmt.Printf("AI分析失败: %v\n", err)
-			return
+			return fmt.Errorf("AI分析失败: %w", err)
 		}
 
 		// 如果启用控制台输出
@@ -84,34 +106,70 @@ var aiAnalysisCmd = &cobra.Command{
 		}
 
 		if err != nil {
-			fmt.Printf("保存分析结果失败: %v\n", err)
-			return
+			return fmt.Errorf("保存分析结果失败: %w", err)
 		}
 
 		// 打印统计信息
 		printAnalysisStats(analyses)
 		fmt.Printf("\n分析结果已保存到: %s\n", outputFile)
+		return scanResult(len(policy.FailedAnalyses(analyses)), partial)
 	},
 }
 
-func saveAnalysisResults(analyses []pkg.AIAnalysis, filename string) error {
-	// 创建包含总结信息的完整报告
-	report := struct {
-		GeneratedAt time.Time        `json:"generated_at"`
-		TotalPods   int              `json:"total_pods"`
-		Summary     map[string]int   `json:"summary"`
-		Analyses    []pkg.AIAnalysis `json:"analyses"`
-	}{
-		GeneratedAt: time.Now(),
-		TotalPods:   len(analyses),
-		Summary:     make(map[string]int),
-		Analyses:    analyses,
+// analyzeClusters 对所有指定集群中的 Pod 进行AI分析，结果按集群顺序合并并记录集群名称。
+// 部分集群或 Pod 分析失败时，已完成的结果和 *pkg.PartialError 一起返回
+func analyzeClusters(analyzer *pkg.AIAnalyzer, options *pflag.FlagSet) ([]pkg.AIAnalysis, error) {
+	scope, err := pkg.ScopeFromFlags(options)
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := pkg.ConnectClusters(options)
+	if err != nil {
+		return nil, fmt.Errorf("连接集群失败: %w", err)
 	}
+	parallel, _ := options.GetInt("parallel")
 
-	// 计算统计信息
-	for _, analysis := range analyses {
-		report.Summary[analysis.SecurityLevel]++
+	results := make([][]pkg.AIAnalysis, len(clusters))
+	partials := make([]error, len(clusters))
+	err = pkg.ForEachCluster(clusters, parallel, func(i int, cluster pkg.ClusterClient) error {
+		// 获取过滤后的Pod列表
+		pods, err := pkg.ListPods(cluster.Client, scope)
+		if err != nil {
+			return fmt.Errorf("获取 Pod 列表失败: %w", err)
+		}
+		if len(pods.Items) == 0 {
+			fmt.Printf("[%s] 没有找到符合条件的Pod\n", cluster.Name)
+			return nil
+		}
+
+		fmt.Printf("[%s] 开始AI安全分析，共 %d 个Pod...\n", cluster.Name, len(pods.Items))
+		// 使用AI分析Pod
+		analyses, err := analyzer.AnalyzePods(pods)
+		if partials[i], err = splitPartial(err); err != nil {
+			return err
+		}
+		pkg.TagAnalysesCluster(analyses, cluster.Name)
+		results[i] = analyses
+		return nil
+	})
+	partial, err := splitPartial(err)
+	if err != nil {
+		return nil, err
+	}
+
+	var analyses []pkg.AIAnalysis
+	for _, r := range results {
+		analyses = append(analyses, r...)
+	}
+	if len(analyses) == 0 {
+		return nil, fmt.Errorf("没有找到符合条件的Pod")
 	}
+	return analyses, errors.Join(append(partials, partial)...)
+}
+
+func saveAnalysisResults(analyses []pkg.AIAnalysis, filename string) error {
+	// 创建包含总结信息的完整报告
+	report := pkg.NewAnalysisReport(analyses)
 
 	// 将报告序列化为JSON
 	data, err := json.MarshalIndent(report, "", "  ")
@@ -162,4 +220,8 @@ func init() {
 	aiAnalysisCmd.Flags().StringP("output", "o", "", "输出文件路径")
 	aiAnalysisCmd.Flags().StringP("format", "f", "json", "输出格式 (json|html)")
 	aiAnalysisCmd.Flags().BoolP("console", "c", false, "在控制台显示详细结果")
+	aiAnalysisCmd.Flags().StringSliceP("manifest", "m", nil, "离线分析的清单文件或目录(可重复，- 表示标准输入)")
+	aiAnalysisCmd.Flags().String("pushgateway", "", "分析结束后把AI分析指标推送到该 Pushgateway 地址，留空表示不推送")
+	addMultiClusterFlags(aiAnalysisCmd)
+	addFailOnFlag(aiAnalysisCmd)
 }
@@ -4,8 +4,18 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"getNoPSS/pkg"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // allNoPSSCmd represents the allNoPSS command
@@ -13,52 +23,140 @@ var allNoPSSCmd = &cobra.Command{
 	Use:   "allNoPSS",
 	Short: "获取所有不安全 Pod",
 	Long:  `检索所有不符合安全标准的 Pod`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		options := cmd.Flags()
-		hostPidCont := pkg.Hostpid(options) //检索使用主机 PID的 Pod
-		pkg.ReportPSS(hostPidCont, "Host PID")
-
-		hostNetCont := pkg.Hostnet(options) //检索使用主机网络的 Pod
-		pkg.ReportPSS(hostNetCont, "Host Network")
-
-		hostIpcCont := pkg.Hostipc(options) //检索使用主机 IPC 的 Pod
-		pkg.ReportPSS(hostIpcCont, "Host IPC")
-
-		hostPorts := pkg.HostPorts(options) //检索使用主机端口的容器
-		pkg.ReportPSS(hostPorts, "Host Ports")
-
-		hostPath := pkg.HostPath(options) //检索挂载主机路径卷的 Pod
-		pkg.ReportPSS(hostPath, "Host Path")
-
-		hostProcessCont := pkg.HostProcess(options) //检索使用 hostprocess 权限运行的容器
-		pkg.ReportPSS(hostProcessCont, "Host Process")
-
-		privCont := pkg.Privileged(options) //检索特权容器
-		pkg.ReportPSS(privCont, "Privileged Container")
-
-		allowPrivEscCont := pkg.AllowPrivEsc(options) //检索允许权限提升的容器
-		pkg.ReportPSS(allowPrivEscCont, "Allow Privilege Escalation")
-
-		capAdded := pkg.AddedCapabilities(options) //检索具有比默认配置更高权限的容器
-		pkg.ReportPSS(capAdded, "Added Capabilities")
-
-		capDropped := pkg.DroppedCapabilities(options) //检索通过降低权限来提高安全性的容器
-		pkg.ReportPSS(capDropped, "Dropped Capabilities")
-
-		seccomp := pkg.Seccomp(options) //检索未启用 Seccomp 的容器
-		pkg.ReportPSS(seccomp, "Seccomp Disabled")
-
-		apparmor := pkg.Apparmor(options) // 检索未启用 AppArmor 的 Pod
-		pkg.ReportPSS(apparmor, "Apparmor Disabled")
+		manifests, _ := options.GetStringSlice("manifest")
+		failOn, _ := options.GetString("fail-on")
+		policy, err := pkg.ParseFailOn(failOn)
+		if err != nil {
+			return err
+		}
+		format, _ := options.GetString("output")
+		if !slices.Contains(pkg.ReportFormats, format) {
+			return fmt.Errorf("--output 不支持 %q，可选: %s", format, strings.Join(pkg.ReportFormats, ", "))
+		}
+		outputFile, _ := options.GetString("output-file")
+		checks, err := profileChecks(options)
+		if err != nil {
+			return err
+		}
+		var suppressions []pkg.Suppression
+		if file, _ := options.GetString("suppressions"); file != "" {
+			if suppressions, err = pkg.LoadSuppressions(file); err != nil {
+				return fmt.Errorf("读取抑制规则失败: %w", err)
+			}
+		}
+
+		var result pkg.ScanResult
+		var partial error
+		if len(manifests) > 0 {
+			// 离线模式：检查清单文件中的 Pod 定义，不连接集群
+			objects, err := pkg.LoadManifests(manifests, os.Stdin)
+			if err != nil {
+				return fmt.Errorf("读取清单失败: %w", err)
+			}
+			result = pkg.ScanManifests(objects, checks)
+		} else {
+			result, err = scanClusters(options, checks)
+			if partial, err = splitPartial(err); err != nil {
+				return fmt.Errorf("扫描集群失败: %w", err)
+			}
+		}
+
+		pkg.ApplySuppressions(&result, suppressions, time.Now())
+		for _, s := range result.ExpiredSuppressions {
+			log.Warn().Str("owner", s.Owner).Str("expires", s.Expires).Msgf("抑制规则已过期: %s", s.String())
+		}
+		if dir, _ := options.GetString("patches"); dir != "" {
+			// 只为没有被抑制的问题生成补丁
+			patches, manual := pkg.BuildPatches(result.Findings)
+			if err := pkg.WritePatchSet(dir, patches); err != nil {
+				return fmt.Errorf("写入补丁失败: %w", err)
+			}
+			log.Info().Msgf("已在 %s 中为 %d 个工作负载生成补丁，%d 个问题需要手动修复", dir, len(patches), len(manual))
+		}
+		showSuppressed, _ := options.GetBool("show-suppressed")
+
+		// 按注册顺序报告所有检查的结果
+		if err := writeReport(outputFile, func(w io.Writer) error {
+			return pkg.WriteReport(w, format, result, pkg.ReportOptions{ShowSuppressed: showSuppressed})
+		}); err != nil {
+			return fmt.Errorf("写入报告失败: %w", err)
+		}
+		return scanResult(len(policy.Failed(result.Findings)), partial)
+	},
+}
 
-		unmaskedProc := pkg.Procmount(options) //检索使用 "Unmasked" proc mount 的容器
-		pkg.ReportPSS(unmaskedProc, "Unmasked Procmount")
+// writeReport 把报告写入 path，path 为空时写入标准输出
+func writeReport(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
 
-		sysctls := pkg.Sysctl(options) //检索配置了不在安全列表中的 sysctl 参数的 Pod
-		pkg.ReportPSS(sysctls, "Unsafe Sysctl")
-	},
+// scanClusters 扫描所有指定的集群，结果按集群顺序合并并记录集群名称。
+// 部分集群扫描失败时，其他集群的结果和 *pkg.PartialError 一起返回
+func scanClusters(options *pflag.FlagSet, checks []pkg.Check) (pkg.ScanResult, error) {
+	scope, err := pkg.ScopeFromFlags(options)
+	if err != nil {
+		return pkg.ScanResult{}, err
+	}
+	clusters, err := pkg.ConnectClusters(options)
+	if err != nil {
+		return pkg.ScanResult{}, err
+	}
+	parallel, _ := options.GetInt("parallel")
+	workloadMode, _ := options.GetBool("workloads")
+
+	results := make([]pkg.ScanResult, len(clusters))
+	err = pkg.ForEachCluster(clusters, parallel, func(i int, cluster pkg.ClusterClient) error {
+		checks := clusterChecks(context.TODO(), options, cluster.Client, checks)
+		var clusterResult pkg.ScanResult
+		if workloadMode {
+			// 直接检查控制器的 Pod 模板，每个工作负载只报告一次
+			workloads, err := pkg.ListWorkloads(cluster.Client, scope)
+			if err != nil {
+				return fmt.Errorf("获取工作负载失败: %w", err)
+			}
+			clusterResult = pkg.ScanWorkloads(workloads, checks)
+		} else {
+			// 分页获取 Pod，每页交给所有检查后即释放
+			var err error
+			clusterResult, err = pkg.ScanCluster(context.TODO(), cluster.Client, scope, checks)
+			if err != nil {
+				return fmt.Errorf("获取 Pod 列表失败: %w", err)
+			}
+		}
+		clusterResult.TagCluster(cluster.Name)
+		results[i] = clusterResult
+		return nil
+	})
+	var result pkg.ScanResult
+	for _, r := range results {
+		result.Merge(r)
+	}
+	return result, err
 }
 
 func init() {
 	rootCmd.AddCommand(allNoPSSCmd)
+	allNoPSSCmd.Flags().StringSliceP("manifest", "m", nil, "离线扫描的清单文件或目录(可重复，- 表示标准输入)")
+	allNoPSSCmd.Flags().BoolP("workloads", "w", false, "直接检查工作负载控制器的 Pod 模板，按工作负载合并结果")
+	addMultiClusterFlags(allNoPSSCmd)
+	addFailOnFlag(allNoPSSCmd)
+	addProfileFlag(allNoPSSCmd)
+	allNoPSSCmd.Flags().StringP("output", "o", pkg.FormatText, "输出格式: "+strings.Join(pkg.ReportFormats, "|"))
+	allNoPSSCmd.Flags().String("output-file", "", "把报告写入文件，默认输出到标准输出")
+	allNoPSSCmd.Flags().String("suppressions", "", "抑制规则文件，匹配的问题不出现在主列表中")
+	allNoPSSCmd.Flags().Bool("show-suppressed", false, "在主列表中同时显示被抑制的问题")
+	allNoPSSCmd.Flags().String("patches", "", "为可以自动修复的问题生成 strategic merge patch 和 kustomize Component，写入该目录")
 }
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"getNoPSS/pkg"
+	"io"
+
+	"github.com/spf13/cobra"
+)
+
+// diffCmd 比较两次扫描的报告
+var diffCmd = &cobra.Command{
+	Use:   "diff old.json new.json",
+	Short: "比较两次扫描的报告",
+	Long: `比较两份 allNoPSS 的 JSON/YAML 报告(-o json|yaml)或两份 aiAnalysis 的 JSON 报告，
+列出新增、已解决和未变化的问题以及每个工作负载的安全等级变化。
+问题按稳定指纹匹配，ReplicaSet 哈希和 Pod 随机后缀的变化不会被当作新问题`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := cmd.Flags()
+		failOn, _ := options.GetString("fail-on")
+		policy, err := pkg.ParseFailOn(failOn)
+		if err != nil {
+			return err
+		}
+		format, _ := options.GetString("output")
+		if format != pkg.FormatText && format != pkg.FormatJSON {
+			return fmt.Errorf("--output 不支持 %q，可选: text, json", format)
+		}
+		outputFile, _ := options.GetString("output-file")
+		showUnchanged, _ := options.GetBool("show-unchanged")
+
+		before, err := pkg.LoadDiffInput(args[0])
+		if err != nil {
+			return err
+		}
+		after, err := pkg.LoadDiffInput(args[1])
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case before.Report != nil && after.Report != nil:
+			diff := pkg.DiffReports(*before.Report, *after.Report)
+			if err := writeReport(outputFile, func(w io.Writer) error {
+				if format == pkg.FormatJSON {
+					return writeJSON(w, diff)
+				}
+				pkg.WriteReportDiff(w, diff, showUnchanged)
+				return nil
+			}); err != nil {
+				return fmt.Errorf("写入报告失败: %w", err)
+			}
+			// 只有新增的问题计入 --fail-on
+			return scanResult(len(policy.FailedRecords(diff.Introduced)), nil)
+		case before.Analysis != nil && after.Analysis != nil:
+			diff := pkg.DiffAnalyses(*before.Analysis, *after.Analysis)
+			if err := writeReport(outputFile, func(w io.Writer) error {
+				if format == pkg.FormatJSON {
+					return writeJSON(w, diff)
+				}
+				pkg.WriteAnalysisDiff(w, diff, showUnchanged)
+				return nil
+			}); err != nil {
+				return fmt.Errorf("写入报告失败: %w", err)
+			}
+			// 新出现的工作负载和等级变差的工作负载计入 --fail-on
+			worse := append([]pkg.AIAnalysis(nil), diff.Introduced...)
+			for _, c := range diff.Changed {
+				if c.Worse() {
+					worse = append(worse, c.Analysis)
+				}
+			}
+			return scanResult(len(policy.FailedAnalyses(worse)), nil)
+		}
+		return fmt.Errorf("无法比较不同类型的报告: %s 和 %s", args[0], args[1])
+	},
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	addFailOnFlag(diffCmd)
+	diffCmd.Flags().StringP("output", "o", pkg.FormatText, "输出格式: text|json")
+	diffCmd.Flags().String("output-file", "", "把结果写入文件，默认输出到标准输出")
+	diffCmd.Flags().Bool("show-unchanged", false, "同时列出未变化的问题")
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"getNoPSS/pkg"
+
+	"github.com/spf13/cobra"
+)
+
+// 命令的退出码，便于在 CI 中区分扫描失败、发现问题和结果不完整
+const (
+	ExitScanError = 1 // 扫描失败，没有可用的结果
+	ExitFindings  = 2 // 存在达到 --fail-on 条件的问题
+	ExitPartial   = 3 // 部分集群或对象扫描失败，结果不完整
+)
+
+// ExitError 携带命令的退出码，Err 为空时只设置退出码不打印错误
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// exitCode 返回错误对应的退出码
+func exitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return ExitScanError
+}
+
+// addFailOnFlag 为可以用于流水线门禁的命令添加 --fail-on
+func addFailOnFlag(cmd *cobra.Command) {
+	cmd.Flags().String("fail-on", "", "达到该严重程度(INFO/LOW/MEDIUM/HIGH/CRITICAL)或属于这些检查 ID 的问题使命令以退出码 2 结束(逗号分隔)")
+}
+
+// scanResult 根据达到 --fail-on 条件的问题数量和部分失败的错误决定命令的结果，
+// 同时存在时返回发现问题的退出码
+func scanResult(failed int, partial error) error {
+	var errs []error
+	code := 0
+	if failed > 0 {
+		errs = append(errs, fmt.Errorf("%d 个问题达到 --fail-on 条件", failed))
+		code = ExitFindings
+	}
+	if partial != nil {
+		errs = append(errs, fmt.Errorf("结果不完整: %w", partial))
+		if code == 0 {
+			code = ExitPartial
+		}
+	}
+	if code == 0 {
+		return nil
+	}
+	return &ExitError{Code: code, Err: errors.Join(errs...)}
+}
+
+// splitPartial 区分部分失败和完全失败：部分失败时返回 partial，其余错误作为 err 返回
+func splitPartial(err error) (partial error, fatal error) {
+	var partialErr *pkg.PartialError
+	if errors.As(err, &partialErr) {
+		return err, nil
+	}
+	return nil, err
+}
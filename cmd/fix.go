@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"getNoPSS/pkg"
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+)
+
+// fixCmd 把修复直接写回清单文件
+var fixCmd = &cobra.Command{
+	Use:   "fix -m <file|dir> (--write|--dry-run)",
+	Short: "自动修复清单文件中的问题",
+	Long: `对 -m 指定的清单执行检查，把可以自动修复的问题直接写回 YAML 文件(--write)，或只输出 unified diff(--dry-run)。
+修改通过 yaml.v3 的节点 API 完成，保留注释、键的顺序和多文档结构，只重新生成包含问题的文档。
+修复后会重新执行检查，确认被修复的问题已经消失，否则不写入任何文件`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := cmd.Flags()
+		manifests, _ := options.GetStringSlice("manifest")
+		write, _ := options.GetBool("write")
+		dryRun, _ := options.GetBool("dry-run")
+		if len(manifests) == 0 {
+			return errors.New("需要用 -m 指定清单文件或目录")
+		}
+		if slices.Contains(manifests, "-") {
+			return errors.New("fix 不支持从标准输入读取清单")
+		}
+		if write == dryRun {
+			return errors.New("需要指定 --write 或 --dry-run 其中之一")
+		}
+		checks, err := profileChecks(options)
+		if err != nil {
+			return err
+		}
+
+		objects, err := pkg.LoadManifests(manifests, nil)
+		if err != nil {
+			return fmt.Errorf("读取清单失败: %w", err)
+		}
+		// 通过注解豁免的问题不修复
+		var findings []pkg.Finding
+		for _, f := range pkg.ScanManifests(objects, checks).Findings {
+			if !f.Suppressed() {
+				findings = append(findings, f)
+			}
+		}
+		fixes, manual, err := pkg.FixManifests(findings)
+		if err != nil {
+			return fmt.Errorf("修复清单失败: %w", err)
+		}
+		remaining, err := pkg.VerifyFixes(fixes, checks)
+		if err != nil {
+			return fmt.Errorf("检查修复结果失败: %w", err)
+		}
+
+		fixed := 0
+		for _, fix := range fixes {
+			fixed += len(fix.Findings)
+			if dryRun {
+				diff, err := fix.Diff()
+				if err != nil {
+					return err
+				}
+				fmt.Print(diff)
+			}
+		}
+		for _, f := range manual {
+			fmt.Fprintf(os.Stderr, "需要手动修复: %s\n", pkg.TitledFinding(f))
+		}
+		if len(remaining) > 0 {
+			for _, f := range remaining {
+				fmt.Fprintf(os.Stderr, "修复后仍然存在: %s\n", pkg.TitledFinding(f))
+			}
+			return fmt.Errorf("%d 个问题修复后仍然存在，没有写入文件", len(remaining))
+		}
+
+		if write {
+			for _, fix := range fixes {
+				if err := writeManifest(fix); err != nil {
+					return fmt.Errorf("写入 %s 失败: %w", fix.File, err)
+				}
+			}
+		}
+		action := "可以修复"
+		if write {
+			action = "已修复"
+		}
+		fmt.Fprintf(os.Stderr, "%s %d 个文件中的 %d 个问题，%d 个问题需要手动修复\n", action, len(fixes), fixed, len(manual))
+		return nil
+	},
+}
+
+// writeManifest 写回修复后的清单，保留原文件的权限
+func writeManifest(fix pkg.ManifestFix) error {
+	info, err := os.Stat(fix.File)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fix.File, fix.Fixed, info.Mode().Perm())
+}
+
+func init() {
+	rootCmd.AddCommand(fixCmd)
+	fixCmd.Flags().StringSliceP("manifest", "m", nil, "要修复的清单文件或目录(可重复)")
+	fixCmd.Flags().Bool("write", false, "把修复写回清单文件")
+	fixCmd.Flags().Bool("dry-run", false, "只输出修复前后的 unified diff，不修改文件")
+	addProfileFlag(fixCmd)
+}
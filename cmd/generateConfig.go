@@ -15,7 +15,7 @@ var generateConfigCmd = &cobra.Command{
 	Use:   "generateConfig",
 	Short: "生成示例配置文件",
 	Long:  `生成一个示例的config.yaml配置文件，包含OpenAI API的配置模板`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		options := cmd.Flags()
 		configPath, _ := options.GetString("output")
 
@@ -25,8 +25,7 @@ var generateConfigCmd = &cobra.Command{
 
 		err := pkg.SaveExampleConfig(configPath)
 		if err != nil {
-			fmt.Printf("❌ 生成配置文件失败: %v\n", err)
-			return
+			return fmt.Errorf("生成配置文件失败: %w", err)
 		}
 
 		fmt.Printf("✅ 示例配置文件已生成: %s\n", configPath)
@@ -36,6 +35,7 @@ var generateConfigCmd = &cobra.Command{
 		fmt.Println("3. 根据需要修改 base_url 和 model")
 		fmt.Println("\n🔍 之后你可以使用以下命令测试配置:")
 		fmt.Printf("./getNoPSS testApi --config %s\n", configPath)
+		return nil
 	},
 }
 
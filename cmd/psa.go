@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"getNoPSS/pkg"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// psaCmd 评估每个命名空间可以使用的 Pod Security Admission 级别
+var psaCmd = &cobra.Command{
+	Use:   "psa",
+	Short: "评估每个命名空间可以使用的 Pod Security Admission 级别",
+	Long: `对每个命名空间计算其中所有 Pod 都满足的最严格 PSS 级别(privileged/baseline/restricted)，
+并与命名空间的 pod-security.kubernetes.io/enforce、audit 和 warn 标签比较，
+列出标签比需要的宽松、以及收紧后会拒绝现有工作负载的命名空间`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := cmd.Flags()
+		format, _ := options.GetString("output")
+		if format != pkg.FormatText && format != pkg.FormatJSON {
+			return fmt.Errorf("--output 不支持 %q，可选: text, json", format)
+		}
+		outputFile, _ := options.GetString("output-file")
+		if _, _, err := pssVersion(options); err != nil {
+			return err
+		}
+
+		namespaces, err := evaluateClusterPSA(options)
+		partial, err := splitPartial(err)
+		if err != nil {
+			return fmt.Errorf("评估命名空间失败: %w", err)
+		}
+		if err := writeReport(outputFile, func(w io.Writer) error {
+			if format == pkg.FormatJSON {
+				return writeJSON(w, namespaces)
+			}
+			return pkg.WritePSAReport(w, namespaces)
+		}); err != nil {
+			return fmt.Errorf("写入报告失败: %w", err)
+		}
+		return scanResult(0, partial)
+	},
+}
+
+// evaluateClusterPSA 扫描所有指定集群中范围内的命名空间和 Pod，结果按集群顺序合并。
+// 部分集群失败时，其他集群的结果和 *pkg.PartialError 一起返回
+func evaluateClusterPSA(options *pflag.FlagSet) ([]pkg.NamespacePSA, error) {
+	scope, err := pkg.ScopeFromFlags(options)
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := pkg.ConnectClusters(options)
+	if err != nil {
+		return nil, err
+	}
+	parallel, _ := options.GetInt("parallel")
+
+	results := make([][]pkg.NamespacePSA, len(clusters))
+	err = pkg.ForEachCluster(clusters, parallel, func(i int, cluster pkg.ClusterClient) error {
+		ctx := context.TODO()
+		inScope, err := scope.NamespaceMatcher(ctx, cluster.Client)
+		if err != nil {
+			return err
+		}
+		list, err := cluster.Client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return fmt.Errorf("获取命名空间失败: %w", err)
+		}
+		var namespaces []corev1.Namespace
+		for _, ns := range list.Items {
+			if inScope(ns.Name) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		// 与 Pod Security Admission 一致，固定了 enforce-version 的命名空间按固定的版本评估
+		checks := pkg.ChecksForVersion(pkg.Checks(), clusterPSSVersion(options, cluster.Client), pkg.NamespaceVersions(list.Items))
+		result, err := pkg.ScanCluster(ctx, cluster.Client, scope, checks)
+		if err != nil {
+			return fmt.Errorf("获取 Pod 列表失败: %w", err)
+		}
+		evaluated := pkg.EvaluatePSA(namespaces, result)
+		for j := range evaluated {
+			evaluated[j].Cluster = cluster.Name
+		}
+		results[i] = evaluated
+		return nil
+	})
+	var namespaces []pkg.NamespacePSA
+	for _, r := range results {
+		namespaces = append(namespaces, r...)
+	}
+	return namespaces, err
+}
+
+func init() {
+	rootCmd.AddCommand(psaCmd)
+	addMultiClusterFlags(psaCmd)
+	addPSSVersionFlag(psaCmd)
+	psaCmd.Flags().StringP("output", "o", pkg.FormatText, "输出格式: text|json")
+	psaCmd.Flags().String("output-file", "", "把结果写入文件，默认输出到标准输出")
+}
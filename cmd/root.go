@@ -4,9 +4,16 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"getNoPSS/pkg"
 	"os"
 
+	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/kubernetes"
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -14,18 +21,107 @@ var rootCmd = &cobra.Command{
 	Use:   "getNoPSS",
 	Short: "不安全 Pod 检索工具",
 	Long:  ``,
+	// 错误由 Execute 统一打印；参数解析完成后的错误不再打印用法
+	SilenceErrors: true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cmd.SilenceUsage = true
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// 命令返回的错误决定退出码，见 ExitError
 func Execute() {
 	err := rootCmd.Execute()
 	if err != nil {
-		os.Exit(1)
+		var exitErr *ExitError
+		if !errors.As(err, &exitErr) || exitErr.Err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+		}
+		os.Exit(exitCode(err))
 	}
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringP("exclude", "e", "", "排除的命名空间列表(逗号分隔)")
+	rootCmd.PersistentFlags().StringP("exclude", "e", "", "排除的命名空间列表，支持精确名称、glob 和 re:正则(逗号分隔)")
+	rootCmd.PersistentFlags().String("kubeconfig", "", "kubeconfig 文件路径(默认使用 KUBECONFIG 环境变量或 ~/.kube/config)")
+	rootCmd.PersistentFlags().String("context", "", "使用的 kubeconfig 上下文")
+	rootCmd.PersistentFlags().String("cluster", "", "使用的 kubeconfig 集群")
+	rootCmd.PersistentFlags().StringSliceP("namespace", "n", nil, "只扫描匹配的命名空间，支持精确名称、glob(team-*)和 re:正则(逗号分隔)")
+	rootCmd.PersistentFlags().StringSlice("include", nil, "同 --namespace，包含的命名空间规则")
+	rootCmd.PersistentFlags().String("namespace-selector", "", "按命名空间标签选择，例如 team=payments")
+	rootCmd.PersistentFlags().StringP("selector", "l", "", "Pod 标签选择器，例如 app=web,tier!=cache")
+	rootCmd.PersistentFlags().String("field-selector", "", "Pod 字段选择器，例如 spec.nodeName=node-1")
+	rootCmd.PersistentFlags().Int64("page-size", pkg.DefaultPageSize, "分页获取 Pod 时每页的数量")
+}
+
+// addMultiClusterFlags 为支持同时扫描多个集群的命令添加参数
+func addMultiClusterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSlice("contexts", nil, "要扫描的 kubeconfig 上下文列表(逗号分隔)")
+	cmd.Flags().Bool("all-contexts", false, "扫描 kubeconfig 中的所有上下文")
+	cmd.Flags().Int("parallel", 1, "同时扫描的集群数量")
+}
+
+// addProfileFlag 为执行检查的命令添加 --profile 和 --pss-version
+func addProfileFlag(cmd *cobra.Command) {
+	// 默认只执行 Baseline 检查，与引入 Restricted 检查之前的结果一致，Restricted 检查需要显式启用
+	cmd.Flags().String("profile", string(pkg.LevelBaseline), "执行的 PSS 级别检查: baseline 只执行 Baseline 检查，restricted 执行全部检查")
+	addPSSVersionFlag(cmd)
+}
+
+// addPSSVersionFlag 为执行检查的命令添加 --pss-version
+func addPSSVersionFlag(cmd *cobra.Command) {
+	cmd.Flags().String("pss-version", "auto", "Pod Security Standards 的版本: v1.23 到 latest，auto 表示使用集群的版本(不连接集群时为 latest)")
+}
 
+// pssVersion 解析 --pss-version，auto 时 detect 为 true
+func pssVersion(options *pflag.FlagSet) (version pkg.PSSVersion, detect bool, err error) {
+	value, _ := options.GetString("pss-version")
+	if value == "auto" {
+		return pkg.LatestPSSVersion, true, nil
+	}
+	if version, err = pkg.ParsePSSVersion(value); err != nil {
+		return version, false, fmt.Errorf("--pss-version 无效: %w", err)
+	}
+	return version, false, nil
+}
+
+// profileChecks 返回 --profile 选择的检查，按 --pss-version 指定的版本执行，auto 时按 latest 执行
+func profileChecks(options *pflag.FlagSet) ([]pkg.Check, error) {
+	profile, _ := options.GetString("profile")
+	level, err := pkg.ParseLevel(profile)
+	if err != nil {
+		return nil, fmt.Errorf("--profile 无效: %w", err)
+	}
+	// Privileged 级别没有任何限制，只在 PSA 命名空间标签中有意义，作为 --profile 时不会执行任何检查
+	if level == pkg.LevelPrivileged {
+		return nil, fmt.Errorf("--profile 不支持 %s: 该级别不执行任何检查，只能用作 PSA 命名空间的级别，请使用 baseline 或 restricted", profile)
+	}
+	version, _, err := pssVersion(options)
+	if err != nil {
+		return nil, err
+	}
+	return pkg.ChecksForVersion(pkg.ChecksForProfile(level), version, nil), nil
+}
+
+// clusterPSSVersion 返回检查集群使用的版本：--pss-version 为 auto 时检测 API Server 的版本，检测失败时使用 latest
+func clusterPSSVersion(options *pflag.FlagSet, client kubernetes.Interface) pkg.PSSVersion {
+	version, detect, _ := pssVersion(options)
+	if !detect {
+		return version
+	}
+	version, err := pkg.ServerPSSVersion(client)
+	if err != nil {
+		log.Warn().Err(err).Msg("检测集群版本失败，按 latest 检查")
+	}
+	return version
+}
+
+// clusterChecks 把检查绑定到集群的版本，通过 enforce-version 标签固定了版本的命名空间按固定的版本检查
+func clusterChecks(ctx context.Context, options *pflag.FlagSet, client kubernetes.Interface, checks []pkg.Check) []pkg.Check {
+	pinned, err := pkg.PinnedVersions(ctx, client)
+	if err != nil {
+		log.Warn().Err(err).Msgf("获取命名空间失败，忽略 %s 标签", pkg.PSAEnforceVersionLabel)
+	}
+	return pkg.ChecksForVersion(checks, clusterPSSVersion(options, client), pinned)
 }
@@ -0,0 +1,107 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"getNoPSS/pkg"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "定期扫描集群并暴露 Prometheus 指标",
+	Long:  `按固定周期执行所有检查，并在 /metrics 暴露问题数量、扫描的 Pod 数量和扫描耗时等指标`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := cmd.Flags()
+		addr, _ := options.GetString("metrics-addr")
+		interval, _ := options.GetDuration("interval")
+
+		checks, err := profileChecks(options)
+		if err != nil {
+			return err
+		}
+		scope, err := pkg.ScopeFromFlags(options)
+		if err != nil {
+			return fmt.Errorf("扫描范围无效: %w", err)
+		}
+		clientset, err := pkg.NewKubeClient(options)
+		if err != nil {
+			return fmt.Errorf("连接集群失败: %w", err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		server := startMetricsServer(ctx, addr)
+		fmt.Printf("指标服务监听于 %s，扫描周期 %s\n", server.Addr, interval)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// 每次扫描重新检测集群版本和命名空间固定的版本
+			scanForMetrics(clientset, scope, clusterChecks(ctx, options, clientset, checks))
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+// scanForMetrics 执行一次完整扫描并更新指标
+func scanForMetrics(clientset kubernetes.Interface, scope *pkg.Scope, checks []pkg.Check) {
+	start := time.Now()
+	result, err := pkg.ScanCluster(context.TODO(), clientset, scope, checks)
+	if err != nil {
+		log.Error().Err(err).Msg("获取 Pod 列表失败")
+		return
+	}
+	duration := time.Since(start)
+
+	pkg.RecordFindings(result.Findings)
+	pkg.RecordScan(result.Pods, duration)
+	log.Info().Int("pods", result.Pods).Int("findings", len(result.Findings)).Dur("duration", duration).Msg("扫描完成")
+}
+
+// startMetricsServer 在后台启动 /metrics 服务，ctx 结束时关闭
+func startMetricsServer(ctx context.Context, addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", pkg.MetricsHandler())
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Error().Err(err).Msg("指标服务异常退出")
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	return server
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+	addProfileFlag(serveCmd)
+	serveCmd.Flags().String("metrics-addr", ":9090", "Prometheus 指标监听地址")
+	serveCmd.Flags().Duration("interval", 5*time.Minute, "扫描周期")
+}
@@ -17,7 +17,7 @@ var testApiCmd = &cobra.Command{
 	Use:   "testApi",
 	Short: "测试OpenAI API连接",
 	Long:  `测试OpenAI API连接是否正常，用于调试配置问题`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("测试OpenAI API连接...")
 
 		// 获取配置文件路径
@@ -27,14 +27,16 @@ var testApiCmd = &cobra.Command{
 		// 加载配置文件
 		config, err := pkg.LoadConfig(configPath)
 		if err != nil {
-			fmt.Printf("❌ 加载配置文件失败: %v\n", err)
-			fmt.Println("\n💡 你可以使用以下命令生成示例配置文件:")
+			fmt.Println("💡 你可以使用以下命令生成示例配置文件:")
 			fmt.Println("./getNoPSS generateConfig")
-			return
+			return fmt.Errorf("加载配置文件失败: %w", err)
 		}
 
 		// 创建AI分析器
-		analyzer := pkg.NewAIAnalyzer(config)
+		analyzer, err := pkg.NewAIAnalyzer(config)
+		if err != nil {
+			return fmt.Errorf("创建AI分析器失败: %w", err)
+		}
 
 		// 进行一个简单的API测试
 		resp, err := analyzer.GetClient().CreateChatCompletion(
@@ -52,18 +54,16 @@ var testApiCmd = &cobra.Command{
 		)
 
 		if err != nil {
-			fmt.Printf("❌ API连接失败: %v\n", err)
-			fmt.Println("\n可能的解决方案:")
+			fmt.Println("可能的解决方案:")
 			fmt.Println("1. 检查配置文件中的 api_key 是否正确")
 			fmt.Println("2. 检查配置文件中的 base_url 是否正确")
 			fmt.Println("3. 确认网络连接正常")
 			fmt.Println("4. 验证API服务是否可用")
-			return
+			return fmt.Errorf("API连接失败: %w", err)
 		}
 
 		if len(resp.Choices) == 0 {
-			fmt.Println("❌ API返回了空响应")
-			return
+			return fmt.Errorf("API返回了空响应")
 		}
 
 		fmt.Println("✅ API连接成功!")
@@ -72,6 +72,7 @@ var testApiCmd = &cobra.Command{
 		fmt.Printf("Token使用情况: %+v\n", resp.Usage)
 		fmt.Printf("配置的Base URL: %s\n", config.OpenAI.BaseURL)
 		fmt.Printf("配置的模型: %s\n", config.OpenAI.Model)
+		return nil
 	},
 }
 
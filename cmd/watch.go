@@ -0,0 +1,97 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"getNoPSS/pkg"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "持续监听 Pod 并报告新出现和已解决的问题",
+	Long: `使用 SharedInformer 持续监听集群中的 Pod，对每次新增和变更执行所有检查，
+首次同步后输出当前的全部问题，之后只输出新出现(NEW)和已解决(RESOLVED)的问题`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := cmd.Flags()
+		resync, _ := options.GetDuration("resync")
+		checks, err := profileChecks(options)
+		if err != nil {
+			return err
+		}
+
+		scope, err := pkg.ScopeFromFlags(options)
+		if err != nil {
+			return fmt.Errorf("扫描范围无效: %w", err)
+		}
+		clientset, err := pkg.NewKubeClient(options)
+		if err != nil {
+			return fmt.Errorf("连接集群失败: %w", err)
+		}
+
+		// 版本在启动时确定，之后修改的 enforce-version 标签需要重启才会生效
+		checks = clusterChecks(context.TODO(), options, clientset, checks)
+		resolver := pkg.NewOwnerResolver(clientset)
+		tracker := pkg.NewFindingTracker(checks, func(pod *corev1.Pod) pkg.Owner {
+			return resolver.Resolve(context.TODO(), pod)
+		})
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		metricsAddr, _ := options.GetString("metrics-addr")
+		if metricsAddr != "" {
+			server := startMetricsServer(ctx, metricsAddr)
+			fmt.Printf("指标服务监听于 %s\n", server.Addr)
+		}
+		updateMetrics := func() {
+			if metricsAddr != "" {
+				pkg.RecordFindings(tracker.Current())
+				pkg.RecordPodsScanned(tracker.PodCount())
+			}
+		}
+
+		fmt.Println("正在同步集群中的 Pod...")
+		start := time.Now()
+		err = pkg.WatchPods(ctx, clientset, scope, resync, tracker, pkg.WatchHandlers{
+			OnSynced: func(current []pkg.Finding) {
+				pkg.RecordScan(tracker.PodCount(), time.Since(start))
+				updateMetrics()
+				fmt.Printf("同步完成，共 %d 个 Pod，%d 个问题\n\n", tracker.PodCount(), len(current))
+				pkg.ReportPSS(current)
+				fmt.Println("开始监听变化...")
+			},
+			OnEvents: func(events []pkg.FindingEvent) {
+				for _, e := range events {
+					title := e.Finding.Check
+					if c, ok := pkg.LookupCheck(e.Finding.Check); ok {
+						title = c.Title()
+					}
+					fmt.Printf("%s [%s] %s: %s\n", time.Now().Format(time.RFC3339), e.Type, title, pkg.FormatFinding(e.Finding))
+				}
+				updateMetrics()
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("监听失败: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(watchCmd)
+	addProfileFlag(watchCmd)
+	watchCmd.Flags().Duration("resync", 10*time.Minute, "informer 的重新同步周期")
+	watchCmd.Flags().String("metrics-addr", "", "Prometheus 指标监听地址，留空表示不启用")
+}
@@ -0,0 +1,119 @@
+/*
+Copyright © 2024 NAME HERE <EMAIL ADDRESS>
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"getNoPSS/pkg"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// webhookCmd represents the webhook command
+var webhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "运行校验型准入 Webhook",
+	Long: `启动一个 TLS AdmissionReview 服务，在准入阶段对 Pod 和工作负载模板执行安全检查，
+根据问题的严重程度放行、警告或拒绝请求。设置了 pod-security.kubernetes.io/enforce-version 标签的命名空间按标签固定的版本检查`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		options := cmd.Flags()
+		addr, _ := options.GetString("addr")
+		certFile, _ := options.GetString("tls-cert-file")
+		keyFile, _ := options.GetString("tls-key-file")
+		denyFlag, _ := options.GetString("deny-severity")
+		warnFlag, _ := options.GetString("warn-severity")
+		overrides, _ := options.GetStringToString("severity-override")
+		exemptNamespaces, _ := options.GetStringSlice("exempt-namespaces")
+
+		checks, err := profileChecks(options)
+		if err != nil {
+			return err
+		}
+		// 收到退出信号后优雅关闭，等待正在处理的请求完成
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		policy := pkg.AdmissionPolicy{Checks: checks, SeverityOverrides: make(map[string]pkg.Severity)}
+		// 与 Pod Security Admission 一致，每个请求按命名空间 enforce-version 标签固定的版本检查，
+		// 无法连接集群时所有请求按 --pss-version 检查(auto 为 latest)
+		if clientset, err := pkg.NewKubeClient(options); err != nil {
+			log.Warn().Err(err).Msgf("连接集群失败，忽略命名空间的 %s 标签", pkg.PSAEnforceVersionLabel)
+		} else {
+			policy.Checks = pkg.ChecksForVersion(checks, clusterPSSVersion(options, clientset), nil)
+			namespaces, err := pkg.WatchNamespaceVersions(ctx, clientset)
+			if err != nil {
+				log.Warn().Err(err).Msgf("获取命名空间失败，忽略命名空间的 %s 标签", pkg.PSAEnforceVersionLabel)
+			} else {
+				policy.PinnedVersion = namespaces.PinnedVersion
+			}
+		}
+		if denyFlag != "" {
+			if policy.DenySeverity, err = pkg.ParseSeverity(denyFlag); err != nil {
+				return fmt.Errorf("--deny-severity 无效: %w", err)
+			}
+		}
+		if warnFlag != "" {
+			if policy.WarnSeverity, err = pkg.ParseSeverity(warnFlag); err != nil {
+				return fmt.Errorf("--warn-severity 无效: %w", err)
+			}
+		}
+		for _, raw := range exemptNamespaces {
+			pattern, err := pkg.ParseNamespacePattern(raw)
+			if err != nil {
+				return fmt.Errorf("--exempt-namespaces 无效: %w", err)
+			}
+			policy.ExemptNamespaces = append(policy.ExemptNamespaces, pattern)
+		}
+		for id, value := range overrides {
+			if _, ok := pkg.LookupCheck(id); !ok {
+				return fmt.Errorf("未知的检查: %s", id)
+			}
+			if policy.SeverityOverrides[id], err = pkg.ParseSeverity(value); err != nil {
+				return fmt.Errorf("检查 %s 的严重程度无效: %w", id, err)
+			}
+		}
+
+		mux := http.NewServeMux()
+		mux.Handle("/validate", pkg.NewAdmissionHandler(policy))
+		mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Printf("准入 Webhook 监听于 %s (拒绝: %s, 警告: %s)\n", addr, denyFlag, warnFlag)
+		if err := server.ListenAndServeTLS(certFile, keyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("Webhook 服务异常退出: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(webhookCmd)
+	addProfileFlag(webhookCmd)
+	webhookCmd.Flags().String("addr", ":8443", "监听地址")
+	webhookCmd.Flags().String("tls-cert-file", "", "TLS 证书文件")
+	webhookCmd.Flags().String("tls-key-file", "", "TLS 私钥文件")
+	webhookCmd.Flags().String("deny-severity", "HIGH", "达到该严重程度的问题会拒绝请求(INFO|LOW|MEDIUM|HIGH|CRITICAL，留空表示从不拒绝)")
+	webhookCmd.Flags().String("warn-severity", "LOW", "达到该严重程度的问题作为警告返回(留空表示不返回警告)")
+	webhookCmd.Flags().StringToString("severity-override", nil, "按检查 ID 覆盖严重程度，例如 hostpath=CRITICAL,hostports=LOW")
+	webhookCmd.Flags().StringSlice("exempt-namespaces", nil, "允许通过 getnopss.io/exempt 注解豁免检查的命名空间(精确名称、glob 或 re: 正则)，默认所有命名空间中的注解豁免都不生效")
+	webhookCmd.MarkFlagRequired("tls-cert-file")
+	webhookCmd.MarkFlagRequired("tls-key-file")
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -13,12 +14,15 @@ import (
 )
 
 type AIAnalysis struct {
-	Namespace       string    `json:"namespace"`
-	Pod             string    `json:"pod"`
-	SecurityLevel   string    `json:"security_level"` // "SAFE", "MODERATE", "HIGH_RISK", "CRITICAL"
-	Issues          []string  `json:"issues"`
-	Recommendations []string  `json:"recommendations"`
-	Timestamp       time.Time `json:"timestamp"`
+	Cluster         string          `json:"cluster,omitempty"`
+	Namespace       string          `json:"namespace"`
+	Pod             string          `json:"pod"`
+	SecurityLevel   string          `json:"security_level"` // "SAFE", "MODERATE", "HIGH_RISK", "CRITICAL"
+	Issues          []string        `json:"issues"`
+	Recommendations []string        `json:"recommendations"`
+	Timestamp       time.Time       `json:"timestamp"`
+	Kind            string          `json:"kind,omitempty"`
+	Source          *ManifestSource `json:"source,omitempty"`
 }
 
 type AIAnalyzer struct {
@@ -26,13 +30,13 @@ type AIAnalyzer struct {
 	model  string
 }
 
-func NewAIAnalyzer(config *Config) *AIAnalyzer {
+func NewAIAnalyzer(config *Config) (*AIAnalyzer, error) {
 	if config == nil {
-		log.Fatal().Msg("Config is required for AI analyzer")
+		return nil, fmt.Errorf("config is required for AI analyzer")
 	}
 
 	if config.OpenAI.APIKey == "" {
-		log.Fatal().Msg("OpenAI API key is required in config")
+		return nil, fmt.Errorf("OpenAI API key is required in config")
 	}
 
 	// 创建配置
@@ -56,7 +60,7 @@ func NewAIAnalyzer(config *Config) *AIAnalyzer {
 	return &AIAnalyzer{
 		client: openai.NewClientWithConfig(openaiConfig),
 		model:  config.OpenAI.Model,
-	}
+	}, nil
 }
 
 // GetClient 返回OpenAI客户端，用于测试连接
@@ -183,8 +187,11 @@ Pod配置：
 	}, nil
 }
 
+// AnalyzePods 逐个分析 Pod，单个 Pod 失败时继续分析其余 Pod。
+// 部分失败时返回已完成的结果和 *PartialError，全部失败时返回错误
 func (ai *AIAnalyzer) AnalyzePods(pods *corev1.PodList) ([]AIAnalysis, error) {
 	var analyses []AIAnalysis
+	var errs []error
 
 	log.Info().Msgf("开始AI分析 %d 个Pods", len(pods.Items))
 
@@ -194,10 +201,13 @@ func (ai *AIAnalyzer) AnalyzePods(pods *corev1.PodList) ([]AIAnalysis, error) {
 		analysis, err := ai.AnalyzePod(&pod)
 		if err != nil {
 			log.Error().Err(err).Msgf("Failed to analyze pod %s/%s", pod.Namespace, pod.Name)
+			recordAIAnalysisError()
+			errs = append(errs, fmt.Errorf("%s/%s: %w", pod.Namespace, pod.Name, err))
 			// 继续处理其他Pod，不因为一个失败而停止
 			continue
 		}
 
+		recordAIAnalysis(analysis.SecurityLevel)
 		analyses = append(analyses, *analysis)
 
 		// 添加延迟以避免API限流
@@ -205,5 +215,58 @@ func (ai *AIAnalyzer) AnalyzePods(pods *corev1.PodList) ([]AIAnalysis, error) {
 	}
 
 	log.Info().Msgf("AI分析完成，共分析了 %d 个Pods", len(analyses))
-	return analyses, nil
+	return analyses, analysisError(errs, len(pods.Items))
+}
+
+// AnalyzeManifests 对清单中提取出的 Pod 进行AI分析，并在结果中记录对象的类型和来源位置。
+// 失败的处理方式与 AnalyzePods 相同
+func (ai *AIAnalyzer) AnalyzeManifests(objects []ManifestObject) ([]AIAnalysis, error) {
+	var analyses []AIAnalysis
+	var errs []error
+
+	log.Info().Msgf("开始AI分析 %d 个清单对象", len(objects))
+
+	for i := range objects {
+		obj := &objects[i]
+		log.Info().Msgf("分析对象 %d/%d: %s %s (%s)", i+1, len(objects), obj.Kind, obj.Pod.Name, obj.Source.File)
+
+		analysis, err := ai.AnalyzePod(&obj.Pod)
+		if err != nil {
+			log.Error().Err(err).Msgf("Failed to analyze %s %s", obj.Kind, obj.Pod.Name)
+			recordAIAnalysisError()
+			errs = append(errs, fmt.Errorf("%s %s (%s): %w", obj.Kind, obj.Pod.Name, obj.Source.File, err))
+			continue
+		}
+		analysis.Kind = obj.Kind
+		src := obj.Source
+		analysis.Source = &src
+
+		recordAIAnalysis(analysis.SecurityLevel)
+		analyses = append(analyses, *analysis)
+
+		// 添加延迟以避免API限流
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	log.Info().Msgf("AI分析完成，共分析了 %d 个清单对象", len(analyses))
+	return analyses, analysisError(errs, len(objects))
+}
+
+// analysisError 汇总分析失败的错误，部分失败时返回 *PartialError
+func analysisError(errs []error, total int) error {
+	switch {
+	case len(errs) == 0:
+		return nil
+	case len(errs) < total:
+		return &PartialError{Failed: len(errs), Total: total, Err: errors.Join(errs...)}
+	default:
+		return fmt.Errorf("所有AI分析均失败: %w", errors.Join(errs...))
+	}
+}
+
+// TagAnalysesCluster 在AI分析结果中记录所属集群
+func TagAnalysesCluster(analyses []AIAnalysis, cluster string) {
+	for i := range analyses {
+		analyses[i].Cluster = cluster
+	}
 }
@@ -0,0 +1,169 @@
+package pkg
+
+import (
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// baselineCapabilities 是 Baseline 级别允许添加的 capabilities，即容器运行时的默认集合
+var baselineCapabilities = []string{
+	"AUDIT_WRITE", "CHOWN", "DAC_OVERRIDE", "FOWNER", "FSETID", "KILL", "MKNOD",
+	"NET_BIND_SERVICE", "SETFCAP", "SETGID", "SETPCAP", "SETUID", "SYS_CHROOT",
+}
+
+// restrictedCapability 是 Restricted 级别唯一允许添加的 capability
+const restrictedCapability = "NET_BIND_SERVICE"
+
+// capabilitySeverity 是添加 Baseline 之外的 capabilities 时的严重程度，未列出的按检查的默认严重程度
+var capabilitySeverity = map[string]Severity{
+	"ALL":             SeverityCritical,
+	"SYS_ADMIN":       SeverityCritical,
+	"SYS_MODULE":      SeverityCritical,
+	"SYS_RAWIO":       SeverityCritical,
+	"SYS_PTRACE":      SeverityHigh,
+	"BPF":             SeverityHigh,
+	"PERFMON":         SeverityHigh,
+	"NET_ADMIN":       SeverityHigh,
+	"DAC_READ_SEARCH": SeverityHigh,
+	"SYS_BOOT":        SeverityHigh,
+	"SYS_TIME":        SeverityHigh,
+	"MAC_ADMIN":       SeverityHigh,
+	"MAC_OVERRIDE":    SeverityHigh,
+	"LINUX_IMMUTABLE": SeverityHigh,
+	"SYSLOG":          SeverityHigh,
+	"NET_RAW":         SeverityMedium,
+	"IPC_LOCK":        SeverityMedium,
+	"SYS_RESOURCE":    SeverityMedium,
+	"SYS_NICE":        SeverityLow,
+	"WAKE_ALARM":      SeverityLow,
+	"BLOCK_SUSPEND":   SeverityLow,
+}
+
+// capabilityEvaluation 是一个容器的 capabilities 相对于 Baseline 和 Restricted 级别的评估结果
+type capabilityEvaluation struct {
+	baseline   []string // 添加的 Baseline 不允许的 capabilities
+	restricted []string // Baseline 允许但 Restricted 不允许添加的 capabilities
+	droppedAll bool     // drop 中包含 ALL
+}
+
+// canonicalCapability 返回大写且去掉 CAP_ 前缀的 capability 名称，容器运行时按同样的方式解析 add 和 drop 中的名称
+func canonicalCapability(name string) string {
+	return strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+}
+
+// evaluateCapabilities 按 Pod Security Standards 评估容器的 capabilities。名称先规范化再比较，
+// 例如 cap_sys_admin 按 SYS_ADMIN 判断，结果中保留清单中的原始写法以便修复时删除。
+// add 中的 ALL 表示添加全部 capabilities，只有 drop 中的 ALL 满足 Restricted
+func evaluateCapabilities(container *corev1.Container) capabilityEvaluation {
+	var e capabilityEvaluation
+	sc := container.SecurityContext
+	if sc == nil || sc.Capabilities == nil {
+		return e
+	}
+	for _, c := range sc.Capabilities.Add {
+		name := canonicalCapability(string(c))
+		switch {
+		case !slices.Contains(baselineCapabilities, name):
+			e.baseline = append(e.baseline, string(c))
+		case name != restrictedCapability:
+			e.restricted = append(e.restricted, string(c))
+		}
+	}
+	e.droppedAll = slices.ContainsFunc(sc.Capabilities.Drop, func(c corev1.Capability) bool {
+		return canonicalCapability(string(c)) == "ALL"
+	})
+	return e
+}
+
+// severityForCapability 返回添加某个 capability 的严重程度，CAP_ 前缀和大小写不影响判断
+func severityForCapability(name string) Severity {
+	return capabilitySeverity[canonicalCapability(name)]
+}
+
+// AddedCapabilities 对每个添加的 Baseline 不允许的 capability 报告一个问题，严重程度取决于 capability 本身
+func AddedCapabilities(pod *corev1.Pod) []Finding {
+	var findings []Finding
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		for _, name := range evaluateCapabilities(container).baseline {
+			p := containerFinding(pod, container, containerType)
+			p.Capabilities = []string{name}
+			p.Severity = severityForCapability(name)
+			findings = append(findings, p)
+		}
+	})
+	return findings
+}
+
+// RestrictedCapabilities 报告没有移除全部 capabilities，或添加了 NET_BIND_SERVICE 之外的 Baseline 允许的 capabilities 的容器，
+// Baseline 不允许的 capabilities 已经由 addedcaps 报告
+func RestrictedCapabilities(pod *corev1.Pod, version PSSVersion) []Finding {
+	if windowsExempt(pod, version) {
+		return nil
+	}
+	var findings []Finding
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		e := evaluateCapabilities(container)
+		if len(e.restricted) == 0 && e.droppedAll {
+			return
+		}
+		p := containerFinding(pod, container, containerType)
+		p.Capabilities = e.restricted
+		if !e.droppedAll {
+			p.Value = "ALL not dropped"
+		}
+		findings = append(findings, p)
+	})
+	return findings
+}
+
+// capabilityChecks 是评估 capabilities 的检查
+var capabilityChecks = []string{"addedcaps", "restrictedcaps"}
+
+// CapabilityStatus 是一个检查过的容器的 capabilities 评估结果
+type CapabilityStatus struct {
+	Object     ScannedObject
+	Container  ScannedContainer
+	Violations []string // 违反的 capabilities 检查，为空表示合规
+}
+
+// Compliant 判断容器是否满足执行过的 capabilities 检查
+func (s CapabilityStatus) Compliant() bool {
+	return len(s.Violations) == 0
+}
+
+// CapabilityStatuses 返回每个检查过的容器相对于执行过的 capabilities 检查的状态，没有违规的容器也会列出。
+// 被豁免或抑制的问题不算违规，没有执行 capabilities 检查时返回 nil
+func CapabilityStatuses(result ScanResult) []CapabilityStatus {
+	var ran []string
+	for _, c := range result.checks() {
+		if slices.Contains(capabilityChecks, c.ID()) {
+			ran = append(ran, c.ID())
+		}
+	}
+	if len(ran) == 0 {
+		return nil
+	}
+	violations := make(map[string][]string)
+	for _, f := range result.Findings {
+		if f.Container == "" || f.Suppressed() || !slices.Contains(ran, f.Check) {
+			continue
+		}
+		key := FindingObject(f).key() + "|" + f.ContainerType + "/" + f.Container
+		if !slices.Contains(violations[key], f.Check) {
+			violations[key] = append(violations[key], f.Check)
+		}
+	}
+	var statuses []CapabilityStatus
+	for _, obj := range result.Objects {
+		for _, c := range obj.Containers {
+			statuses = append(statuses, CapabilityStatus{
+				Object:     obj,
+				Container:  c,
+				Violations: violations[obj.key()+"|"+c.Type+"/"+c.Name],
+			})
+		}
+	}
+	return statuses
+}
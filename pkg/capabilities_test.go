@@ -0,0 +1,283 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func capabilityContainer(add, drop []corev1.Capability) corev1.Container {
+	c := corev1.Container{Name: "app"}
+	if add != nil || drop != nil {
+		c.SecurityContext = &corev1.SecurityContext{Capabilities: &corev1.Capabilities{Add: add, Drop: drop}}
+	}
+	return c
+}
+
+func TestEvaluateCapabilities(t *testing.T) {
+	tests := []struct {
+		name string
+		add  []corev1.Capability
+		drop []corev1.Capability
+		want capabilityEvaluation
+	}{
+		{name: "no capabilities"},
+		{name: "drop ALL", drop: []corev1.Capability{"ALL"}, want: capabilityEvaluation{droppedAll: true}},
+		{name: "drop all in lower case", drop: []corev1.Capability{"all"}, want: capabilityEvaluation{droppedAll: true}},
+		{name: "drop CAP_ALL", drop: []corev1.Capability{"CAP_ALL"}, want: capabilityEvaluation{droppedAll: true}},
+		{name: "drop some", drop: []corev1.Capability{"NET_RAW", "CHOWN"}},
+		{name: "NET_BIND_SERVICE allowed by both", add: []corev1.Capability{"NET_BIND_SERVICE"}, drop: []corev1.Capability{"ALL"},
+			want: capabilityEvaluation{droppedAll: true}},
+		{name: "baseline allowed", add: []corev1.Capability{"CHOWN", "KILL", "NET_BIND_SERVICE"},
+			want: capabilityEvaluation{restricted: []string{"CHOWN", "KILL"}}},
+		{name: "baseline violations", add: []corev1.Capability{"SYS_ADMIN", "CHOWN", "NET_RAW"},
+			want: capabilityEvaluation{baseline: []string{"SYS_ADMIN", "NET_RAW"}, restricted: []string{"CHOWN"}}},
+		{name: "add ALL", add: []corev1.Capability{"ALL"}, drop: []corev1.Capability{"ALL"},
+			want: capabilityEvaluation{baseline: []string{"ALL"}, droppedAll: true}},
+		// 规范化后再比较，结果保留原始写法
+		{name: "lower case and CAP_ prefix", add: []corev1.Capability{"cap_sys_admin", "CAP_NET_RAW", "cap_chown", "Net_Bind_Service"},
+			want: capabilityEvaluation{baseline: []string{"cap_sys_admin", "CAP_NET_RAW"}, restricted: []string{"cap_chown"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := capabilityContainer(tt.add, tt.drop)
+			if got := evaluateCapabilities(&c); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("evaluateCapabilities = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddedCapabilities(t *testing.T) {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		Containers: []corev1.Container{
+			capabilityContainer([]corev1.Capability{"cap_sys_admin", "CHOWN", "NET_RAW"}, nil),
+			{Name: "clean"},
+		},
+		InitContainers: []corev1.Container{capabilityContainer([]corev1.Capability{"SYS_PTRACE", "ALL", "SYS_NICE", "IPC_OWNER"}, nil)},
+	}}
+	pod.Spec.InitContainers[0].Name = "init"
+
+	type result struct {
+		container  string
+		capability string
+		severity   Severity
+	}
+	want := []result{
+		{"app", "cap_sys_admin", SeverityCritical},
+		{"app", "NET_RAW", SeverityMedium},
+		{"init", "SYS_PTRACE", SeverityHigh},
+		{"init", "ALL", SeverityCritical},
+		{"init", "SYS_NICE", SeverityLow},
+		{"init", "IPC_OWNER", ""}, // 没有单独的严重程度，使用检查的默认值
+	}
+	var got []result
+	for _, f := range AddedCapabilities(pod) {
+		if len(f.Capabilities) != 1 {
+			t.Fatalf("finding has capabilities %v, want one", f.Capabilities)
+		}
+		got = append(got, result{f.Container, f.Capabilities[0], f.Severity})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AddedCapabilities = %+v, want %+v", got, want)
+	}
+}
+
+func TestRestrictedCapabilities(t *testing.T) {
+	windows := func(pod *corev1.Pod) *corev1.Pod {
+		pod.Spec.OS = &corev1.PodOS{Name: corev1.Windows}
+		return pod
+	}
+	pod := func(containers ...corev1.Container) *corev1.Pod {
+		for i := range containers {
+			containers[i].Name = string(rune('a' + i))
+		}
+		return &corev1.Pod{Spec: corev1.PodSpec{Containers: containers}}
+	}
+	type result struct {
+		container    string
+		capabilities []string
+		value        string
+	}
+	tests := []struct {
+		name    string
+		pod     *corev1.Pod
+		version PSSVersion
+		want    []result
+	}{
+		{name: "drop ALL", pod: pod(capabilityContainer(nil, []corev1.Capability{"ALL"}))},
+		{name: "drop all with NET_BIND_SERVICE", pod: pod(capabilityContainer([]corev1.Capability{"cap_net_bind_service"}, []corev1.Capability{"all"}))},
+		{name: "nothing dropped", pod: pod(corev1.Container{}, capabilityContainer(nil, []corev1.Capability{"NET_RAW"})), want: []result{
+			{container: "a", value: "ALL not dropped"},
+			{container: "b", value: "ALL not dropped"},
+		}},
+		{name: "baseline allowed capability added", pod: pod(capabilityContainer([]corev1.Capability{"CHOWN", "NET_BIND_SERVICE"}, []corev1.Capability{"ALL"})), want: []result{
+			{container: "a", capabilities: []string{"CHOWN"}},
+		}},
+		// Baseline 不允许的 capabilities 由 addedcaps 报告
+		{name: "baseline violation only", pod: pod(capabilityContainer([]corev1.Capability{"SYS_ADMIN"}, []corev1.Capability{"ALL"}))},
+		{name: "windows pod exempt", pod: windows(pod(corev1.Container{}))},
+		{name: "windows pod before v1.25", pod: windows(pod(corev1.Container{})), version: PSSVersion{Minor: 24}, want: []result{
+			{container: "a", value: "ALL not dropped"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []result
+			for _, f := range RestrictedCapabilities(tt.pod, tt.version) {
+				got = append(got, result{f.Container, f.Capabilities, f.Value})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RestrictedCapabilities = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// capabilityScan 扫描 web 和 worker 两个 Pod：web 的 app 容器添加了 SYS_ADMIN，
+// sidecar 和初始化容器 init 合规；worker 的 app 容器添加了 CHOWN
+func capabilityScan(checks []Check) ScanResult {
+	dropAll := []corev1.Capability{"ALL"}
+	web := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				capabilityContainer([]corev1.Capability{"SYS_ADMIN"}, dropAll),
+				capabilityContainer(nil, dropAll),
+			},
+			InitContainers: []corev1.Container{capabilityContainer(nil, dropAll)},
+		},
+	}
+	web.Spec.Containers[1].Name = "sidecar"
+	web.Spec.InitContainers[0].Name = "init"
+	worker := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "worker"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{capabilityContainer([]corev1.Capability{"CHOWN"}, dropAll)}},
+	}
+	scanner := NewPodScanner(fake.NewSimpleClientset(), checks)
+	scanner.Scan([]corev1.Pod{web, worker})
+	return scanner.Result()
+}
+
+func TestCapabilityStatuses(t *testing.T) {
+	type status struct {
+		object     string
+		container  string
+		violations []string
+	}
+	statuses := func(result ScanResult) []status {
+		var got []status
+		for _, s := range CapabilityStatuses(result) {
+			if s.Compliant() != (len(s.Violations) == 0) {
+				t.Errorf("%s %s: Compliant() = %v with violations %v", s.Object, s.Container, s.Compliant(), s.Violations)
+			}
+			got = append(got, status{s.Object.String(), s.Container.String(), s.Violations})
+		}
+		return got
+	}
+	tests := []struct {
+		name     string
+		level    Level
+		suppress bool
+		want     []status
+	}{
+		{name: "baseline", level: LevelBaseline, want: []status{
+			{"default/Pod/web", "container app", []string{"addedcaps"}},
+			{"default/Pod/web", "container sidecar", nil},
+			{"default/Pod/web", "initContainer init", nil},
+			{"default/Pod/worker", "container app", nil},
+		}},
+		{name: "restricted", level: LevelRestricted, want: []status{
+			{"default/Pod/web", "container app", []string{"addedcaps"}},
+			{"default/Pod/web", "container sidecar", nil},
+			{"default/Pod/web", "initContainer init", nil},
+			{"default/Pod/worker", "container app", []string{"restrictedcaps"}},
+		}},
+		// 被抑制的问题不算违规
+		{name: "suppressed", level: LevelRestricted, suppress: true, want: []status{
+			{"default/Pod/web", "container app", nil},
+			{"default/Pod/web", "container sidecar", nil},
+			{"default/Pod/web", "initContainer init", nil},
+			{"default/Pod/worker", "container app", []string{"restrictedcaps"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := capabilityScan(ChecksForProfile(tt.level))
+			if tt.suppress {
+				for i := range result.Findings {
+					if result.Findings[i].Check == "addedcaps" {
+						result.Findings[i].Suppression = &Suppression{Check: "addedcaps", Reason: "test"}
+					}
+				}
+			}
+			if got := statuses(result); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CapabilityStatuses = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	hostPID, _ := LookupCheck("hostpid")
+	if got := CapabilityStatuses(capabilityScan([]Check{hostPID})); got != nil {
+		t.Errorf("CapabilityStatuses without capability checks = %+v, want nil", got)
+	}
+}
+
+func TestCapabilityReport(t *testing.T) {
+	result := capabilityScan(ChecksForProfile(LevelRestricted))
+
+	var buf bytes.Buffer
+	if err := WriteReport(&buf, FormatJSON, result, ReportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var report Report
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	want := []CapabilityRecord{
+		{Namespace: "default", Object: "Pod/web", Container: "app", ContainerType: "container", Status: CapabilityViolating, Violations: []string{"addedcaps"}},
+		{Namespace: "default", Object: "Pod/web", Container: "sidecar", ContainerType: "container", Status: CapabilityCompliant},
+		{Namespace: "default", Object: "Pod/web", Container: "init", ContainerType: "initContainer", Status: CapabilityCompliant},
+		{Namespace: "default", Object: "Pod/worker", Container: "app", ContainerType: "container", Status: CapabilityViolating, Violations: []string{"restrictedcaps"}},
+	}
+	if !reflect.DeepEqual(report.Capabilities, want) {
+		t.Errorf("JSON capabilities = %+v, want %+v", report.Capabilities, want)
+	}
+
+	buf.Reset()
+	if err := WriteReport(&buf, FormatTable, result, ReportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_, table, ok := strings.Cut(buf.String(), "\n\n")
+	if !ok {
+		t.Fatalf("table report has no capabilities table:\n%s", buf.String())
+	}
+	wantTable := `NAMESPACE  OBJECT      CONTAINER             CAPABILITIES
+default    Pod/web     app                   violating (addedcaps)
+default    Pod/web     sidecar               compliant
+default    Pod/web     init (initContainer)  compliant
+default    Pod/worker  app                   violating (restrictedcaps)
+`
+	if table != wantTable {
+		t.Errorf("capabilities table = \n%s\nwant:\n%s", table, wantTable)
+	}
+
+	buf.Reset()
+	if err := WriteReport(&buf, FormatText, result, ReportOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"namespace default : pod web : container sidecar : compliant",
+		"namespace default : pod worker : container app : violating (restrictedcaps)",
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("text report is missing %q:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Level 表示检查项所属的 Pod Security Standards 级别
+type Level string
+
+const (
+	LevelPrivileged Level = "privileged" // 不做任何限制，只用于命名空间的 Pod Security Admission 级别
+	LevelBaseline   Level = "baseline"
+	LevelRestricted Level = "restricted"
+)
+
+var levelRank = map[Level]int{
+	LevelPrivileged: 0,
+	LevelBaseline:   1,
+	LevelRestricted: 2,
+}
+
+// Rank 返回级别的排序值，数值越大越严格，未知值返回 -1
+func (l Level) Rank() int {
+	if r, ok := levelRank[l]; ok {
+		return r
+	}
+	return -1
+}
+
+// ParseLevel 解析不区分大小写的 PSS 级别
+func ParseLevel(s string) (Level, error) {
+	level := Level(strings.ToLower(strings.TrimSpace(s)))
+	if level.Rank() < 0 {
+		return "", fmt.Errorf("unknown level %q (supported: privileged, baseline, restricted)", s)
+	}
+	return level, nil
+}
+
+// Severity 表示检查项的默认严重程度
+type Severity string
+
+const (
+	SeverityInfo     Severity = "INFO"
+	SeverityLow      Severity = "LOW"
+	SeverityMedium   Severity = "MEDIUM"
+	SeverityHigh     Severity = "HIGH"
+	SeverityCritical Severity = "CRITICAL"
+)
+
+var severityRank = map[Severity]int{
+	SeverityInfo:     0,
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// Rank 返回严重程度的排序值，数值越大越严重，未知值返回 -1
+func (s Severity) Rank() int {
+	if r, ok := severityRank[s]; ok {
+		return r
+	}
+	return -1
+}
+
+// ParseSeverity 解析不区分大小写的严重程度字符串
+func ParseSeverity(s string) (Severity, error) {
+	sev := Severity(strings.ToUpper(strings.TrimSpace(s)))
+	if sev.Rank() < 0 {
+		return "", fmt.Errorf("unknown severity %q", s)
+	}
+	return sev, nil
+}
+
+// Check 是一条 Pod 安全检查规则，对单个 Pod 求值并返回发现的问题
+type Check interface {
+	ID() string
+	Title() string
+	Level() Level
+	Severity() Severity
+	Description() string
+	Remediation() string
+	Evaluate(pod *corev1.Pod) []Finding
+}
+
+// CheckInfo 描述一条检查规则的元数据
+type CheckInfo struct {
+	ID          string
+	Title       string
+	Level       Level
+	Severity    Severity
+	Description string
+	Remediation string
+}
+
+// VersionedCheck 是规则随 Kubernetes 版本变化的检查，Evaluate 按 latest 求值
+type VersionedCheck interface {
+	Check
+	EvaluateVersion(pod *corev1.Pod, version PSSVersion) []Finding
+}
+
+type funcCheck struct {
+	info     CheckInfo
+	evaluate func(pod *corev1.Pod, version PSSVersion) []Finding
+}
+
+// NewCheck 用元数据和逐 Pod 的求值函数构造一条检查规则
+func NewCheck(info CheckInfo, evaluate func(pod *corev1.Pod) []Finding) Check {
+	return &funcCheck{info: info, evaluate: func(pod *corev1.Pod, _ PSSVersion) []Finding { return evaluate(pod) }}
+}
+
+// NewVersionedCheck 用元数据和按版本求值的函数构造一条检查规则
+func NewVersionedCheck(info CheckInfo, evaluate func(pod *corev1.Pod, version PSSVersion) []Finding) Check {
+	return &funcCheck{info: info, evaluate: evaluate}
+}
+
+func (c *funcCheck) ID() string                         { return c.info.ID }
+func (c *funcCheck) Title() string                      { return c.info.Title }
+func (c *funcCheck) Level() Level                       { return c.info.Level }
+func (c *funcCheck) Severity() Severity                 { return c.info.Severity }
+func (c *funcCheck) Description() string                { return c.info.Description }
+func (c *funcCheck) Remediation() string                { return c.info.Remediation }
+func (c *funcCheck) Evaluate(pod *corev1.Pod) []Finding { return c.evaluate(pod, LatestPSSVersion) }
+
+func (c *funcCheck) EvaluateVersion(pod *corev1.Pod, version PSSVersion) []Finding {
+	return c.evaluate(pod, version)
+}
+
+// versionBoundCheck 按绑定的版本执行 VersionedCheck，pinned 中的命名空间按各自固定的版本执行
+type versionBoundCheck struct {
+	VersionedCheck
+	version PSSVersion
+	pinned  map[string]PSSVersion
+}
+
+func (c *versionBoundCheck) Evaluate(pod *corev1.Pod) []Finding {
+	version := c.version
+	if v, ok := c.pinned[pod.Namespace]; ok {
+		version = v
+	}
+	return c.EvaluateVersion(pod, version)
+}
+
+// ChecksForVersion 返回按 version 执行的检查，pinned 中的命名空间按各自固定的版本执行。
+// 不随版本变化的检查原样返回，已经绑定过版本的检查会重新绑定
+func ChecksForVersion(checks []Check, version PSSVersion, pinned map[string]PSSVersion) []Check {
+	bound := make([]Check, len(checks))
+	for i, c := range checks {
+		if b, ok := c.(*versionBoundCheck); ok {
+			c = b.VersionedCheck
+		}
+		if vc, ok := c.(VersionedCheck); ok {
+			c = &versionBoundCheck{VersionedCheck: vc, version: version, pinned: pinned}
+		}
+		bound[i] = c
+	}
+	return bound
+}
+
+var registry []Check
+
+// Register 注册一条检查规则，检查按注册顺序执行和报告。ID 重复时 panic
+func Register(c Check) {
+	if c == nil {
+		panic("pkg: Register check is nil")
+	}
+	if _, dup := LookupCheck(c.ID()); dup {
+		panic("pkg: Register called twice for check " + c.ID())
+	}
+	registry = append(registry, c)
+}
+
+// Checks 返回所有已注册的检查规则
+func Checks() []Check {
+	return append([]Check(nil), registry...)
+}
+
+// ChecksForProfile 返回 profile 级别包含的检查：baseline 只包含 Baseline 检查，restricted 包含全部检查
+func ChecksForProfile(profile Level) []Check {
+	var checks []Check
+	for _, c := range registry {
+		if c.Level().Rank() <= profile.Rank() {
+			checks = append(checks, c)
+		}
+	}
+	return checks
+}
+
+// LookupCheck 按 ID 查找已注册的检查规则
+func LookupCheck(id string) (Check, bool) {
+	for _, c := range registry {
+		if c.ID() == id {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// EvaluatePod 对单个 Pod 执行给定的检查，并在结果中补全检查 ID、严重程度和级别。
+// Pod 通过 getnopss.io/exempt 注解豁免的检查仍然返回问题，并在 Suppression 中记录豁免
+func EvaluatePod(pod *corev1.Pod, checks []Check) []Finding {
+	var findings []Finding
+	exemptions := podExemptions(pod)
+	for _, c := range checks {
+		for _, f := range c.Evaluate(pod) {
+			f.Check = c.ID()
+			if f.Severity == "" {
+				f.Severity = c.Severity()
+			}
+			f.Level = c.Level()
+			f.Suppression = exemptionFor(exemptions, c.ID())
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// RunChecks 对同一份 Pod 快照执行给定的检查
+func RunChecks(pods *corev1.PodList, checks []Check) []Finding {
+	var findings []Finding
+	for i := range pods.Items {
+		findings = append(findings, EvaluatePod(&pods.Items[i], checks)...)
+	}
+	return findings
+}
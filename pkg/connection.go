@@ -2,21 +2,46 @@ package pkg
 
 import (
 	"context"
-	"strings"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/pflag"
 	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
-func initKubeClient() (*kubernetes.Clientset, error) {
-	//初始化 Kubernetes 客户端,使用 clientcmd 包加载 kubeconfig 文件并创建一个客户端配置
+// KubeOptions 是连接集群时使用的 kubeconfig 选项，空值表示使用 kubeconfig 中的默认值
+type KubeOptions struct {
+	Kubeconfig string
+	Context    string
+	Cluster    string
+}
+
+// KubeOptionsFromFlags 从命令行选项中读取 --kubeconfig、--context 和 --cluster
+func KubeOptionsFromFlags(options *pflag.FlagSet) KubeOptions {
+	kubeconfig, _ := options.GetString("kubeconfig")
+	kubeContext, _ := options.GetString("context")
+	cluster, _ := options.GetString("cluster")
+	return KubeOptions{Kubeconfig: kubeconfig, Context: kubeContext, Cluster: cluster}
+}
+
+func (o KubeOptions) clientConfig() clientcmd.ClientConfig {
+	//使用 clientcmd 包加载 kubeconfig 文件，命令行指定的文件和上下文优先
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
-	config, err := kubeConfig.ClientConfig()
+	loadingRules.ExplicitPath = o.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: o.Context}
+	overrides.Context.Cluster = o.Cluster
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+}
+
+func initKubeClient(opts KubeOptions) (*kubernetes.Clientset, error) {
+	//初始化 Kubernetes 客户端,创建一个客户端配置
+	config, err := opts.clientConfig().ClientConfig()
 	if err != nil {
 		log.Error().Err(err).Msg("initKubeClient: failed creating ClientConfig")
 		return nil, err
@@ -31,44 +56,191 @@ func initKubeClient() (*kubernetes.Clientset, error) {
 	return clientset, nil
 }
 
-func ConnectWithPods(options *pflag.FlagSet) *corev1.PodList {
-	// 调用 initKubeClient() 初始化 Kubernetes 客户
-	clientset, err := initKubeClient()
+// NewKubeClient 按命令行中的 kubeconfig 选项创建 Kubernetes 客户端
+func NewKubeClient(options *pflag.FlagSet) (kubernetes.Interface, error) {
+	return initKubeClient(KubeOptionsFromFlags(options))
+}
+
+// ClusterClient 是一个需要扫描的集群，Name 为对应的 kubeconfig 上下文名称，连接失败时 Err 不为空
+type ClusterClient struct {
+	Name   string
+	Client kubernetes.Interface
+	Err    error
+}
+
+// ConnectClusters 连接需要扫描的所有集群：--contexts 列出的上下文、--all-contexts 时的全部上下文，
+// 或者默认只连接 --context(当前上下文)。无法连接的上下文记录在 ClusterClient.Err 中，只有 kubeconfig 无法加载时返回错误
+func ConnectClusters(options *pflag.FlagSet) ([]ClusterClient, error) {
+	base := KubeOptionsFromFlags(options)
+	contexts, _ := options.GetStringSlice("contexts")
+	allContexts, _ := options.GetBool("all-contexts")
+
+	raw, err := base.clientConfig().RawConfig()
 	if err != nil {
-		log.Print(err)
+		return nil, fmt.Errorf("加载 kubeconfig 失败: %w", err)
 	}
-	// 使用初始化的客户端获取所有 Pod 的列表
-	pods, err := clientset.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{})
-	if err != nil {
-		log.Print(err)
+	if allContexts {
+		contexts = contexts[:0]
+		for name := range raw.Contexts {
+			contexts = append(contexts, name)
+		}
+		sort.Strings(contexts)
 	}
-	// 根据传递的命令行选项中的 exclude 参数，过滤不需要的 Pod
-	exclude, err := options.GetString("exclude")
+	if len(contexts) == 0 {
+		name := base.Context
+		if name == "" {
+			name = raw.CurrentContext
+		}
+		contexts = []string{name}
+	}
+
+	var clusters []ClusterClient
+	for _, name := range contexts {
+		opts := base
+		opts.Context = name
+		clientset, err := initKubeClient(opts)
+		if err != nil {
+			// 单个上下文无法连接时继续扫描其他集群，由 ForEachCluster 计入失败的集群
+			clusters = append(clusters, ClusterClient{Name: name, Err: fmt.Errorf("连接失败: %w", err)})
+			continue
+		}
+		clusters = append(clusters, ClusterClient{Name: name, Client: clientset})
+	}
+	return clusters, nil
+}
 
-	var excludeList []string
-	if exclude != "" { //如果 exclude 参数不为空，则排除指定命名空间的 Pod
-		excludeList = strings.Split(exclude, ",")
+// ForEachCluster 对每个集群调用 fn，parallel 为同时扫描的集群数量(小于 1 时按 1 处理)。
+// 单个集群失败不会中断其他集群，所有错误合并后返回；只有部分集群失败时返回 *PartialError。
+// 连接失败的集群不调用 fn，直接计为失败
+func ForEachCluster(clusters []ClusterClient, parallel int, fn func(i int, cluster ClusterClient) error) error {
+	if parallel < 1 {
+		parallel = 1
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, parallel)
+	errs := make([]error, len(clusters))
+	for i, cluster := range clusters {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, cluster ClusterClient) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := cluster.Err
+			if err == nil {
+				err = fn(i, cluster)
+			}
+			if err != nil {
+				errs[i] = fmt.Errorf("集群 %s: %w", cluster.Name, err)
+			}
+		}(i, cluster)
+	}
+	wg.Wait()
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return nil
+	case failed < len(clusters):
+		return &PartialError{Failed: failed, Total: len(clusters), Err: errors.Join(errs...)}
+	default:
+		return errors.Join(errs...)
 	}
+}
+
+// DefaultPageSize 是分页获取 Pod 时每页的默认数量
+const DefaultPageSize = 500
+
+// ListPods 使用给定的客户端获取扫描范围内的 Pod，标签和字段选择器在服务端过滤，命名空间规则在本地过滤。
+// 结果会全部保存在内存中，大集群请使用 ForEachPodPage 逐页处理
+func ListPods(clientset kubernetes.Interface, scope *Scope) (*corev1.PodList, error) {
+	pods := &corev1.PodList{}
+	err := ForEachPodPage(context.TODO(), clientset, scope, func(page []corev1.Pod) error {
+		pods.Items = append(pods.Items, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pods, nil
+}
+
+// maxRelists 是一次遍历中 continue 令牌过期后允许重新获取的次数，超过时返回错误，避免 Pod 持续变化时无法结束
+const maxRelists = 5
+
+// ForEachPodPage 使用 Limit/Continue 分页获取扫描范围内的 Pod，每获取一页就交给 fn 处理，
+// 同一时间只有一页 Pod 保存在内存中。continue 令牌过期(410 Gone)时优先使用 API Server 在错误中返回的
+// inconsistent continue 令牌从同一位置继续，没有时从头重新获取。API Server 按 namespace/name 的顺序返回 Pod，
+// 因此只需要记录最后处理的 Pod 就能跳过已经处理过的部分。过期超过 maxRelists 次时返回错误
+func ForEachPodPage(ctx context.Context, clientset kubernetes.Interface, scope *Scope, fn func(pods []corev1.Pod) error) error {
+	inScope, err := scope.NamespaceMatcher(ctx, clientset)
 	if err != nil {
-		log.Print(err)
+		return err
 	}
-	//返回经过过滤的 Pod 列表
-	filteredPods := &corev1.PodList{}
-	for _, pod := range pods.Items {
-		if len(excludeList) > 0 {
-			excluded := false
-			for _, s := range excludeList {
-				if strings.Contains(pod.Namespace, s) {
-					excluded = true
-					break
-				}
+	opts := scope.ListOptions()
+	opts.Limit = scope.PageSize
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultPageSize
+	}
+
+	var last string // 最后处理的 Pod 的 namespace/name
+	relists := 0
+	for {
+		list, err := clientset.CoreV1().Pods(scope.ListNamespace()).List(ctx, opts)
+		if apierrors.IsResourceExpired(err) || apierrors.IsGone(err) {
+			if opts.Continue == "" {
+				return err
+			}
+			if relists++; relists > maxRelists {
+				return fmt.Errorf("continue token expired %d times: %w", maxRelists, err)
 			}
-			if excluded {
+			opts.Continue = ""
+			var status apierrors.APIStatus
+			if errors.As(err, &status) {
+				opts.Continue = status.Status().ListMeta.Continue
+			}
+			if opts.Continue != "" {
+				log.Warn().Err(err).Msg("continue 令牌已过期，使用 API Server 返回的令牌继续获取 Pod 列表")
+			} else {
+				log.Warn().Err(err).Msg("continue 令牌已过期，重新获取 Pod 列表")
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		page := list.Items[:0]
+		for _, pod := range list.Items {
+			key := pod.Namespace + "/" + pod.Name
+			// 重新获取后跳过已经处理过的 Pod，首次获取时每个 Pod 只会出现一次
+			if relists > 0 && key <= last {
 				continue
 			}
+			last = key
+			if inScope(pod.Namespace) {
+				page = append(page, pod)
+			}
 		}
-		filteredPods.Items = append(filteredPods.Items, pod)
+		if len(page) > 0 {
+			if err := fn(page); err != nil {
+				return err
+			}
+		}
+
+		if list.Continue == "" {
+			return nil
+		}
+		opts.Continue = list.Continue
 	}
+}
 
-	return filteredPods
+// TagCluster 在问题中记录所属集群
+func TagCluster(findings []Finding, cluster string) {
+	for i := range findings {
+		findings[i].Cluster = cluster
+	}
 }
@@ -0,0 +1,218 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// pagedPods 模拟按 namespace/name 顺序分页返回 Pod 的 API Server。fake.Clientset 会丢弃 Limit 和 Continue，
+// 所以 Pod 的 List 由这里实现，Pod 在请求时生成，测量的内存只包含扫描本身
+type pagedPods struct {
+	total        int
+	expireAt     int  // 第几次请求返回 410 Gone，0 表示不过期
+	expireFrom   int  // 大于 0 时从第几次请求开始，所有带 continue 令牌的请求都返回 410 Gone
+	inconsistent bool // 410 Gone 中带有从同一位置继续的 inconsistent continue 令牌
+	requests     int
+	fullLists    int // 不带 continue 令牌的请求次数
+}
+
+func (p *pagedPods) pod(i int) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: fmt.Sprintf("ns-%03d", i/1000),
+			Name:      fmt.Sprintf("pod-%06d", i),
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx:1.25"}}},
+	}
+}
+
+func (p *pagedPods) list(opts metav1.ListOptions) (*corev1.PodList, error) {
+	p.requests++
+	if opts.Continue == "" {
+		p.fullLists++
+	}
+	expired := p.requests == p.expireAt || (p.expireFrom > 0 && p.requests >= p.expireFrom)
+	if expired && opts.Continue != "" {
+		err := apierrors.NewResourceExpired("the provided continue parameter is too old")
+		if p.inconsistent {
+			err.ErrStatus.ListMeta.Continue = opts.Continue
+		}
+		return nil, err
+	}
+	start := 0
+	if opts.Continue != "" {
+		start, _ = strconv.Atoi(opts.Continue)
+	}
+	end := p.total
+	if opts.Limit > 0 && start+int(opts.Limit) < end {
+		end = start + int(opts.Limit)
+	}
+	list := &corev1.PodList{}
+	for i := start; i < end; i++ {
+		list.Items = append(list.Items, p.pod(i))
+	}
+	if end < p.total {
+		list.Continue = strconv.Itoa(end)
+	}
+	return list, nil
+}
+
+// newPagingClientset 返回 Pod 由 pods 分页提供、其他资源使用 fake.Clientset 的客户端
+func newPagingClientset(pods *pagedPods) kubernetes.Interface {
+	return &pagingClientset{Clientset: fake.NewSimpleClientset(), pods: pods}
+}
+
+type pagingClientset struct {
+	*fake.Clientset
+	pods *pagedPods
+}
+
+func (c *pagingClientset) CoreV1() corev1client.CoreV1Interface {
+	return pagingCoreV1{CoreV1Interface: c.Clientset.CoreV1(), pods: c.pods}
+}
+
+type pagingCoreV1 struct {
+	corev1client.CoreV1Interface
+	pods *pagedPods
+}
+
+func (c pagingCoreV1) Pods(namespace string) corev1client.PodInterface {
+	return pagingPodClient{PodInterface: c.CoreV1Interface.Pods(namespace), pods: c.pods}
+}
+
+type pagingPodClient struct {
+	corev1client.PodInterface
+	pods *pagedPods
+}
+
+func (c pagingPodClient) List(_ context.Context, opts metav1.ListOptions) (*corev1.PodList, error) {
+	return c.pods.list(opts)
+}
+
+func TestForEachPodPage(t *testing.T) {
+	tests := []struct {
+		name         string
+		expireAt     int
+		inconsistent bool
+		exclude      string
+		want         int
+		wantLists    int // 不带 continue 令牌的请求次数
+	}{
+		{name: "all pages", want: 2500, wantLists: 1},
+		{name: "continue token expires", expireAt: 7, want: 2500, wantLists: 2},
+		{name: "inconsistent continue token", expireAt: 7, inconsistent: true, want: 2500, wantLists: 1},
+		{name: "excluded namespace", expireAt: 4, exclude: "ns-001", want: 1500, wantLists: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods := &pagedPods{total: 2500, expireAt: tt.expireAt, inconsistent: tt.inconsistent}
+			scope := &Scope{PageSize: 200}
+			if tt.exclude != "" {
+				pattern, err := ParseNamespacePattern(tt.exclude)
+				if err != nil {
+					t.Fatal(err)
+				}
+				scope.Exclude = []NamespacePattern{pattern}
+			}
+
+			seen := make(map[string]int)
+			err := ForEachPodPage(context.Background(), newPagingClientset(pods), scope, func(page []corev1.Pod) error {
+				if len(page) > int(scope.PageSize) {
+					t.Errorf("page has %d pods, want at most %d", len(page), scope.PageSize)
+				}
+				for _, pod := range page {
+					if pod.Namespace == tt.exclude {
+						t.Errorf("pod %s/%s is out of scope", pod.Namespace, pod.Name)
+					}
+					seen[pod.Namespace+"/"+pod.Name]++
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(seen) != tt.want {
+				t.Errorf("got %d pods, want %d", len(seen), tt.want)
+			}
+			for key, n := range seen {
+				if n != 1 {
+					t.Errorf("pod %s handled %d times", key, n)
+				}
+			}
+			if pods.fullLists != tt.wantLists {
+				t.Errorf("listed from the beginning %d times, want %d", pods.fullLists, tt.wantLists)
+			}
+		})
+	}
+}
+
+func TestForEachPodPageRepeatedExpiry(t *testing.T) {
+	tests := []struct {
+		name         string
+		inconsistent bool
+		want         int // 请求次数
+	}{
+		// 前两次请求成功，之后每次从头获取的第一页成功、下一页过期
+		{name: "relist from the beginning", want: 2 + 2*maxRelists + 1},
+		// 每次都使用错误中的令牌继续，立即再次过期
+		{name: "inconsistent continue token", inconsistent: true, want: 2 + maxRelists + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pods := &pagedPods{total: 2500, expireFrom: 3, inconsistent: tt.inconsistent}
+			err := ForEachPodPage(context.Background(), newPagingClientset(pods), &Scope{PageSize: 200}, func([]corev1.Pod) error {
+				return nil
+			})
+			if !apierrors.IsResourceExpired(err) {
+				t.Fatalf("err = %v, want resource expired", err)
+			}
+			if pods.requests != tt.want {
+				t.Errorf("got %d requests, want %d", pods.requests, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkForEachPodPage 逐页遍历 100k 个 Pod，peak-heap-MB 是遍历过程中存活堆内存相对开始时的峰值，
+// 应该只与页大小有关，与 Pod 总数无关
+func BenchmarkForEachPodPage(b *testing.B) {
+	const total = 100000
+	for i := 0; i < b.N; i++ {
+		client := newPagingClientset(&pagedPods{total: total, expireAt: 150})
+		runtime.GC()
+		var base runtime.MemStats
+		runtime.ReadMemStats(&base)
+
+		var peak uint64
+		pages, count := 0, 0
+		err := ForEachPodPage(context.Background(), client, &Scope{}, func(page []corev1.Pod) error {
+			count += len(page)
+			if pages++; pages%20 == 0 {
+				runtime.GC()
+				var m runtime.MemStats
+				runtime.ReadMemStats(&m)
+				if m.HeapAlloc > base.HeapAlloc && m.HeapAlloc-base.HeapAlloc > peak {
+					peak = m.HeapAlloc - base.HeapAlloc
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+		if count != total {
+			b.Fatalf("got %d pods, want %d", count, total)
+		}
+		b.ReportMetric(float64(peak)/(1<<20), "peak-heap-MB")
+	}
+}
@@ -0,0 +1,269 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DiffInput 是 diff 命令读取的一份报告，Report 和 Analysis 只有一个非空
+type DiffInput struct {
+	Report   *Report         // allNoPSS 的 JSON 或 YAML 报告
+	Analysis *AnalysisReport // aiAnalysis 的 JSON 报告
+}
+
+// LoadDiffInput 读取报告文件并根据内容判断报告类型
+func LoadDiffInput(file string) (*DiffInput, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report %s: %w", file, err)
+	}
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext == ".yaml" || ext == ".yml" {
+		var report Report
+		if err := yaml.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("failed to parse report %s: %w", file, err)
+		}
+		if err := checkSchemaVersion(file, report.SchemaVersion); err != nil {
+			return nil, err
+		}
+		return &DiffInput{Report: &report}, nil
+	}
+	var probe struct {
+		SchemaVersion string          `json:"schema_version"`
+		Analyses      json.RawMessage `json:"analyses"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse report %s: %w", file, err)
+	}
+	switch {
+	case probe.SchemaVersion != "":
+		if err := checkSchemaVersion(file, probe.SchemaVersion); err != nil {
+			return nil, err
+		}
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("failed to parse report %s: %w", file, err)
+		}
+		return &DiffInput{Report: &report}, nil
+	case probe.Analyses != nil:
+		var analysis AnalysisReport
+		if err := json.Unmarshal(data, &analysis); err != nil {
+			return nil, fmt.Errorf("failed to parse report %s: %w", file, err)
+		}
+		return &DiffInput{Analysis: &analysis}, nil
+	}
+	return nil, fmt.Errorf("%s is neither an allNoPSS json/yaml report nor an aiAnalysis json report", file)
+}
+
+func checkSchemaVersion(file, version string) error {
+	if version == "" {
+		return fmt.Errorf("%s has no schema_version, expected an allNoPSS json/yaml report", file)
+	}
+	if version != ReportSchemaVersion {
+		return fmt.Errorf("%s: unsupported schema_version %q (supported: %s)", file, version, ReportSchemaVersion)
+	}
+	return nil
+}
+
+// ReportDiff 是两份 PSS 报告按问题指纹比较的结果，被抑制的问题不参与比较
+type ReportDiff struct {
+	Introduced []ReportRecord `json:"introduced"`
+	Resolved   []ReportRecord `json:"resolved"`
+	Unchanged  []ReportRecord `json:"unchanged"`
+}
+
+// DiffReports 比较两份报告中未被抑制的问题。同一指纹出现多次时只保留第一条，
+// 例如没有解析到控制器的多个 Pod 副本
+func DiffReports(before, after Report) ReportDiff {
+	oldRecords := fingerprintIndex(before.Findings)
+	newRecords := fingerprintIndex(after.Findings)
+	diff := ReportDiff{Introduced: []ReportRecord{}, Resolved: []ReportRecord{}, Unchanged: []ReportRecord{}}
+	for _, r := range newRecords.records {
+		if _, ok := oldRecords.byFingerprint[r.Fingerprint]; ok {
+			diff.Unchanged = append(diff.Unchanged, r)
+		} else {
+			diff.Introduced = append(diff.Introduced, r)
+		}
+	}
+	for _, r := range oldRecords.records {
+		if _, ok := newRecords.byFingerprint[r.Fingerprint]; !ok {
+			diff.Resolved = append(diff.Resolved, r)
+		}
+	}
+	return diff
+}
+
+type recordIndex struct {
+	records       []ReportRecord
+	byFingerprint map[string]bool
+}
+
+// fingerprintIndex 重新计算每条记录的指纹，旧版本报告中没有 fingerprint 字段
+func fingerprintIndex(records []ReportRecord) recordIndex {
+	index := recordIndex{byFingerprint: make(map[string]bool)}
+	for _, r := range records {
+		r.Fingerprint = Fingerprint(r)
+		if index.byFingerprint[r.Fingerprint] {
+			continue
+		}
+		index.byFingerprint[r.Fingerprint] = true
+		index.records = append(index.records, r)
+	}
+	return index
+}
+
+// LevelChange 是同一工作负载在两次AI分析之间的安全等级变化
+type LevelChange struct {
+	Subject  string     `json:"subject"`
+	Old      string     `json:"old"`
+	New      string     `json:"new"`
+	Analysis AIAnalysis `json:"analysis"` // 新报告中等级最高的一条分析
+}
+
+// Worse 判断安全等级是否变差
+func (c LevelChange) Worse() bool {
+	return AnalysisSeverity(c.New).Rank() > AnalysisSeverity(c.Old).Rank()
+}
+
+// AnalysisDiff 是两份AI分析报告按工作负载比较的结果
+type AnalysisDiff struct {
+	Introduced []AIAnalysis  `json:"introduced"`
+	Removed    []AIAnalysis  `json:"removed"`
+	Changed    []LevelChange `json:"changed"`
+	Unchanged  []AIAnalysis  `json:"unchanged"`
+}
+
+// DiffAnalyses 比较两份AI分析报告。同一工作负载的多个 Pod 合并为一项，取其中最高的安全等级
+func DiffAnalyses(before, after AnalysisReport) AnalysisDiff {
+	oldKeys, oldWorst := worstAnalyses(before.Analyses)
+	newKeys, newWorst := worstAnalyses(after.Analyses)
+	diff := AnalysisDiff{Introduced: []AIAnalysis{}, Removed: []AIAnalysis{}, Changed: []LevelChange{}, Unchanged: []AIAnalysis{}}
+	for _, key := range newKeys {
+		a := newWorst[key]
+		prev, ok := oldWorst[key]
+		switch {
+		case !ok:
+			diff.Introduced = append(diff.Introduced, a)
+		case !strings.EqualFold(prev.SecurityLevel, a.SecurityLevel):
+			diff.Changed = append(diff.Changed, LevelChange{Subject: key, Old: prev.SecurityLevel, New: a.SecurityLevel, Analysis: a})
+		default:
+			diff.Unchanged = append(diff.Unchanged, a)
+		}
+	}
+	for _, key := range oldKeys {
+		if _, ok := newWorst[key]; !ok {
+			diff.Removed = append(diff.Removed, oldWorst[key])
+		}
+	}
+	// 变差的排在前面
+	sort.SliceStable(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].Worse() && !diff.Changed[j].Worse()
+	})
+	return diff
+}
+
+func worstAnalyses(analyses []AIAnalysis) ([]string, map[string]AIAnalysis) {
+	var keys []string
+	worst := make(map[string]AIAnalysis)
+	for _, a := range analyses {
+		key := analysisKey(a)
+		prev, ok := worst[key]
+		if !ok {
+			keys = append(keys, key)
+		}
+		if !ok || AnalysisSeverity(a.SecurityLevel).Rank() > AnalysisSeverity(prev.SecurityLevel).Rank() {
+			worst[key] = a
+		}
+	}
+	return keys, worst
+}
+
+// WriteReportDiff 以文本形式写出 PSS 报告的比较结果，showUnchanged 为 false 时未变化的问题只显示数量
+func WriteReportDiff(w io.Writer, diff ReportDiff, showUnchanged bool) {
+	fmt.Fprintf(w, "新增 %d, 已解决 %d, 未变化 %d\n\n", len(diff.Introduced), len(diff.Resolved), len(diff.Unchanged))
+	writeRecordSection(w, "Introduced findings", "+", diff.Introduced)
+	writeRecordSection(w, "Resolved findings", "-", diff.Resolved)
+	if showUnchanged {
+		writeRecordSection(w, "Unchanged findings", " ", diff.Unchanged)
+	}
+}
+
+func writeRecordSection(w io.Writer, title, marker string, records []ReportRecord) {
+	fmt.Fprintln(w, title)
+	if len(records) == 0 {
+		fmt.Fprintln(w, "None")
+	}
+	for _, r := range records {
+		fmt.Fprintf(w, "%s %s %s : %s\n", marker, r.Severity, r.Title, formatRecord(r))
+	}
+	fmt.Fprintln(w, "")
+}
+
+// formatRecord 与 FormatFinding 的格式相同，用于没有原始 Finding 的报告记录
+func formatRecord(r ReportRecord) string {
+	line := fmt.Sprintf("namespace %s : %s", r.Namespace, recordSubject(r))
+	if r.Cluster != "" {
+		line = fmt.Sprintf("cluster %s : %s", r.Cluster, line)
+	}
+	if r.Container != "" {
+		line += fmt.Sprintf(" : container %s", r.Container)
+	}
+	if r.Details != "" {
+		line += " : " + r.Details
+	}
+	if r.Source != nil {
+		line += fmt.Sprintf(" (%s:%d)", r.Source.File, r.Source.Line)
+	}
+	return line
+}
+
+func recordSubject(r ReportRecord) string {
+	switch {
+	case r.OwnerKind != "":
+		return strings.ToLower(r.OwnerKind) + " " + StableName(r.OwnerKind, r.OwnerName)
+	case r.Kind != "":
+		return strings.ToLower(r.Kind) + " " + r.Pod
+	default:
+		return "pod " + StableName("", r.Pod)
+	}
+}
+
+// WriteAnalysisDiff 以文本形式写出AI分析报告的比较结果
+func WriteAnalysisDiff(w io.Writer, diff AnalysisDiff, showUnchanged bool) {
+	fmt.Fprintf(w, "新增 %d, 已移除 %d, 等级变化 %d, 未变化 %d\n\n", len(diff.Introduced), len(diff.Removed), len(diff.Changed), len(diff.Unchanged))
+	fmt.Fprintln(w, "Security level changes")
+	if len(diff.Changed) == 0 {
+		fmt.Fprintln(w, "None")
+	}
+	for _, c := range diff.Changed {
+		marker := "▼"
+		if c.Worse() {
+			marker = "▲"
+		}
+		fmt.Fprintf(w, "%s %s : %s -> %s\n", marker, c.Subject, c.Old, c.New)
+	}
+	fmt.Fprintln(w, "")
+	writeAnalysisSection(w, "New workloads", "+", diff.Introduced)
+	writeAnalysisSection(w, "Removed workloads", "-", diff.Removed)
+	if showUnchanged {
+		writeAnalysisSection(w, "Unchanged workloads", " ", diff.Unchanged)
+	}
+}
+
+func writeAnalysisSection(w io.Writer, title, marker string, analyses []AIAnalysis) {
+	fmt.Fprintln(w, title)
+	if len(analyses) == 0 {
+		fmt.Fprintln(w, "None")
+	}
+	for _, a := range analyses {
+		fmt.Fprintf(w, "%s %s : %s\n", marker, analysisKey(a), a.SecurityLevel)
+	}
+	fmt.Fprintln(w, "")
+}
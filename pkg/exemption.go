@@ -0,0 +1,50 @@
+package pkg
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// 工作负载可以在 Pod(或 Pod 模板)的注解中豁免指定的检查，豁免的问题仍会被报告并标记
+const (
+	ExemptAnnotation       = "getnopss.io/exempt"        // 逗号分隔的检查 ID，* 表示所有检查
+	ExemptReasonAnnotation = "getnopss.io/exempt-reason" // 豁免原因
+)
+
+// podExemptions 解析 Pod 上的豁免注解，返回检查 ID 到豁免规则的映射，没有豁免时返回 nil。
+// 未知的检查 ID(例如拼写错误)不会豁免任何问题，记录警告后忽略
+func podExemptions(pod *corev1.Pod) map[string]*Suppression {
+	value, ok := pod.Annotations[ExemptAnnotation]
+	if !ok {
+		return nil
+	}
+	reason := strings.TrimSpace(pod.Annotations[ExemptReasonAnnotation])
+	exemptions := make(map[string]*Suppression)
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		if _, known := LookupCheck(id); !known && id != "*" {
+			log.Warn().Msgf("%s/%s 的 %s 注解中有未知的检查 %q，已忽略", pod.Namespace, pod.Name, ExemptAnnotation, id)
+			continue
+		}
+		exemptions[id] = &Suppression{
+			Check:     id,
+			Namespace: pod.Namespace,
+			Pod:       pod.Name,
+			Reason:    reason,
+			Source:    SuppressionSourceAnnotation,
+		}
+	}
+	return exemptions
+}
+
+// exemptionFor 返回豁免指定检查的规则
+func exemptionFor(exemptions map[string]*Suppression, check string) *Suppression {
+	if s, ok := exemptions[check]; ok {
+		return s
+	}
+	return exemptions["*"]
+}
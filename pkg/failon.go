@@ -0,0 +1,121 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PartialError 表示只有部分对象处理成功，其余结果仍然有效
+type PartialError struct {
+	Failed int
+	Total  int
+	Err    error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d/%d 处理失败: %v", e.Failed, e.Total, e.Err)
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// FailPolicy 决定哪些问题会让扫描以失败结束，由 --fail-on 指定
+type FailPolicy struct {
+	Severity Severity        // 达到该严重程度的问题视为失败，空值表示不按严重程度判断
+	Checks   map[string]bool // 这些检查的问题无论严重程度都视为失败
+}
+
+// ParseFailOn 解析逗号分隔的严重程度或检查 ID 列表，例如 HIGH 或 privileged,hostpath。
+// 同时给出多个严重程度时取最低的一个
+func ParseFailOn(spec string) (*FailPolicy, error) {
+	policy := &FailPolicy{Checks: make(map[string]bool)}
+	for _, raw := range strings.Split(spec, ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		if sev, err := ParseSeverity(raw); err == nil {
+			if policy.Severity == "" || sev.Rank() < policy.Severity.Rank() {
+				policy.Severity = sev
+			}
+			continue
+		}
+		if _, ok := LookupCheck(raw); !ok {
+			return nil, fmt.Errorf("--fail-on: %q 既不是严重程度也不是已注册的检查", raw)
+		}
+		policy.Checks[raw] = true
+	}
+	return policy, nil
+}
+
+// Empty 判断是否没有设置任何失败条件
+func (p *FailPolicy) Empty() bool {
+	return p == nil || (p.Severity == "" && len(p.Checks) == 0)
+}
+
+// Failed 返回满足失败条件的问题，被抑制的问题不计入
+func (p *FailPolicy) Failed(findings []Finding) []Finding {
+	if p.Empty() {
+		return nil
+	}
+	var failed []Finding
+	for _, f := range findings {
+		if f.Suppressed() {
+			continue
+		}
+		if p.Checks[f.Check] || p.exceeds(f.Severity) {
+			failed = append(failed, f)
+		}
+	}
+	return failed
+}
+
+// FailedRecords 返回满足失败条件的报告记录，被抑制的记录不计入
+func (p *FailPolicy) FailedRecords(records []ReportRecord) []ReportRecord {
+	if p.Empty() {
+		return nil
+	}
+	var failed []ReportRecord
+	for _, r := range records {
+		if r.Suppression != nil && !r.Suppression.Expired {
+			continue
+		}
+		if p.Checks[r.CheckID] || p.exceeds(r.Severity) {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// FailedAnalyses 返回安全等级达到阈值的AI分析结果，检查 ID 不适用于AI分析
+func (p *FailPolicy) FailedAnalyses(analyses []AIAnalysis) []AIAnalysis {
+	if p.Empty() {
+		return nil
+	}
+	var failed []AIAnalysis
+	for _, a := range analyses {
+		if p.exceeds(AnalysisSeverity(a.SecurityLevel)) {
+			failed = append(failed, a)
+		}
+	}
+	return failed
+}
+
+func (p *FailPolicy) exceeds(sev Severity) bool {
+	return p.Severity != "" && sev != "" && sev.Rank() >= p.Severity.Rank()
+}
+
+// AnalysisSeverity 把AI分析的安全等级映射为严重程度，无法识别的等级返回空值
+func AnalysisSeverity(level string) Severity {
+	switch strings.ToUpper(level) {
+	case "SAFE":
+		return SeverityInfo
+	case "MODERATE":
+		return SeverityMedium
+	case "HIGH_RISK":
+		return SeverityHigh
+	case "CRITICAL":
+		return SeverityCritical
+	}
+	return ""
+}
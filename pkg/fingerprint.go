@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Kubernetes 生成名称后缀使用的字符集，不含元音和容易混淆的数字
+const generatedAlphabet = "[bcdfghjklmnpqrstvwxz2456789]"
+
+var (
+	replicaSetPodName = regexp.MustCompile(`^(.+)-` + generatedAlphabet + `{6,10}-` + generatedAlphabet + `{5}$`)
+	generatedPodName  = regexp.MustCompile(`^(.+)-` + generatedAlphabet + `{5}$`)
+	replicaSetName    = regexp.MustCompile(`^(.+)-` + generatedAlphabet + `{6,10}$`)
+	cronJobJobName    = regexp.MustCompile(`^(.+)-[0-9]{8,}$`)
+)
+
+// StableName 去掉控制器生成的名称后缀，例如 ReplicaSet 的 pod-template-hash 和 Pod 的随机后缀，
+// 使同一工作负载在不同扫描中得到相同的名称
+func StableName(kind, name string) string {
+	var patterns []*regexp.Regexp
+	switch kind {
+	case "", "Pod":
+		patterns = []*regexp.Regexp{replicaSetPodName, generatedPodName}
+	case "ReplicaSet":
+		patterns = []*regexp.Regexp{replicaSetName}
+	case "Job":
+		patterns = []*regexp.Regexp{cronJobJobName}
+	}
+	for _, p := range patterns {
+		if m := p.FindStringSubmatch(name); m != nil {
+			return m[1]
+		}
+	}
+	return name
+}
+
+// Fingerprint 返回问题的稳定指纹：相同工作负载、容器和具体内容的问题在不同扫描中指纹相同，
+// 与 Pod 名称、镜像版本、副本数和清单中的文档位置无关。Value 是违规的具体配置值(例如 UID 或 profile)，
+// 修改配置值或把设置从容器移到 Pod 级别时问题仍然是同一个，因此不参与计算。
+// 来自已废弃注解的问题与来自字段的问题是不同的问题(例如同一容器的 AppArmor 字段和注解)，来源参与计算
+func Fingerprint(r ReportRecord) string {
+	sum := sha256.Sum256([]byte(fingerprintKey(r)))
+	return hex.EncodeToString(sum[:8])
+}
+
+func fingerprintKey(r ReportRecord) string {
+	kind, name := "Pod", r.Pod
+	switch {
+	case r.OwnerKind != "":
+		kind, name = r.OwnerKind, r.OwnerName
+	case r.Kind != "":
+		kind = r.Kind
+	}
+	caps := slices.Clone(r.Capabilities)
+	slices.Sort(caps)
+	var file string
+	if r.Source != nil {
+		file = r.Source.File
+	}
+	parts := []string{
+		r.CheckID, r.Cluster, r.Namespace, kind, StableName(kind, name), r.Container,
+		strings.Join(caps, ","), strconv.Itoa(r.HostPort), r.Volume, r.Path, r.Sysctl, file,
+	}
+	// 只为注解来源追加，来自字段的问题的指纹与之前的版本保持一致
+	if annotationSource(r.Value) {
+		parts = append(parts, "annotation")
+	}
+	return strings.Join(parts, "|")
+}
+
+// annotationSource 判断问题来自已废弃的注解，例如 Unconfined (annotation) 或 Unconfined (pod annotation)
+func annotationSource(value string) bool {
+	return strings.HasSuffix(value, "annotation)")
+}
+
+// analysisKey 返回AI分析对象的稳定标识，同一工作负载的不同 Pod 得到相同的标识
+func analysisKey(a AIAnalysis) string {
+	key := StableName(a.Kind, a.Pod)
+	if a.Kind != "" {
+		key = a.Kind + "/" + key
+	}
+	key = a.Namespace + "/" + key
+	if a.Cluster != "" {
+		key = a.Cluster + "/" + key
+	}
+	if a.Source != nil {
+		key += "@" + a.Source.File
+	}
+	return key
+}
@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestStableName(t *testing.T) {
+	tests := []struct {
+		kind, name, want string
+	}{
+		{"Pod", "web-7d9c5b6f4b-x2k8p", "web"},
+		{"Pod", "api-server-5f6b8c9d7-abcde", "api-server-5f6b8c9d7-abcde"}, // 后缀含元音，不是生成的名称
+		{"Pod", "node-exporter-4xz2q", "node-exporter"},
+		{"", "node-exporter-4xz2q", "node-exporter"},
+		{"Pod", "web", "web"},
+		{"Pod", "db-0", "db-0"}, // StatefulSet 的序号是稳定的
+		{"ReplicaSet", "web-7d9c5b6f4b", "web"},
+		{"ReplicaSet", "web", "web"},
+		{"Job", "backup-28930140", "backup"},
+		{"Job", "migrate", "migrate"},
+		{"Deployment", "web-7d9c5b6f4b", "web-7d9c5b6f4b"},
+	}
+	for _, tt := range tests {
+		if got := StableName(tt.kind, tt.name); got != tt.want {
+			t.Errorf("StableName(%q, %q) = %q, want %q", tt.kind, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	base := ReportRecord{
+		CheckID: "hostpath", Namespace: "default", OwnerKind: "Deployment", OwnerName: "web",
+		Pod: "web-7d9c5b6f4b-x2k8p", Container: "app", Volume: "data", Path: "/var/lib",
+	}
+	want := Fingerprint(base)
+	if len(want) != 16 {
+		t.Errorf("Fingerprint = %q, want 16 hex characters", want)
+	}
+
+	same := map[string]func(r *ReportRecord){
+		"pod name":  func(r *ReportRecord) { r.Pod = "web-7d9c5b6f4b-q8m2z" },
+		"image":     func(r *ReportRecord) { r.Image = "nginx:1.27" },
+		"replicas":  func(r *ReportRecord) { r.Replicas = 3 },
+		"value":     func(r *ReportRecord) { r.Value = "0 (pod)" },
+		"severity":  func(r *ReportRecord) { r.Severity = SeverityCritical },
+		"document":  func(r *ReportRecord) { r.Source = &RecordLocation{Document: 2, Line: 40} },
+		"old field": func(r *ReportRecord) { r.Fingerprint = "stale" },
+	}
+	for name, mutate := range same {
+		r := base
+		mutate(&r)
+		if got := Fingerprint(r); got != want {
+			t.Errorf("changing %s changed the fingerprint: %s, want %s", name, got, want)
+		}
+	}
+
+	different := map[string]func(r *ReportRecord){
+		"check":      func(r *ReportRecord) { r.CheckID = "hostpid" },
+		"cluster":    func(r *ReportRecord) { r.Cluster = "prod" },
+		"namespace":  func(r *ReportRecord) { r.Namespace = "kube-system" },
+		"owner":      func(r *ReportRecord) { r.OwnerName = "api" },
+		"container":  func(r *ReportRecord) { r.Container = "sidecar" },
+		"path":       func(r *ReportRecord) { r.Path = "/etc" },
+		"file":       func(r *ReportRecord) { r.Source = &RecordLocation{File: "deploy.yaml"} },
+		"annotation": func(r *ReportRecord) { r.Value = "unconfined (annotation)" },
+	}
+	for name, mutate := range different {
+		r := base
+		mutate(&r)
+		if got := Fingerprint(r); got == want {
+			t.Errorf("changing %s did not change the fingerprint", name)
+		}
+	}
+
+	sorted := ReportRecord{CheckID: "addedcaps", Pod: "web", Capabilities: []string{"SYS_ADMIN", "NET_RAW"}}
+	reordered := sorted
+	reordered.Capabilities = []string{"NET_RAW", "SYS_ADMIN"}
+	if Fingerprint(sorted) != Fingerprint(reordered) {
+		t.Error("capability order changed the fingerprint")
+	}
+}
+
+// 同一容器的 AppArmor 字段和注解都违规时是两个问题，指纹不能相同
+func TestFingerprintAppArmorSources(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "web",
+			Annotations: map[string]string{apparmorAnnotationPrefix + "app": "unconfined"},
+		},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:            "app",
+			SecurityContext: &corev1.SecurityContext{AppArmorProfile: &corev1.AppArmorProfile{Type: corev1.AppArmorProfileTypeUnconfined}},
+		}}},
+	}
+	check, _ := LookupCheck("apparmor")
+	records := ReportRecords(EvaluatePod(pod, []Check{check}))
+	if len(records) != 2 {
+		t.Fatalf("got %d AppArmor findings, want field and annotation: %+v", len(records), records)
+	}
+	if records[0].Fingerprint == records[1].Fingerprint {
+		t.Errorf("field (%s) and annotation (%s) findings share fingerprint %s", records[0].Value, records[1].Value, records[0].Fingerprint)
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+	record := func(check, owner, container string) ReportRecord {
+		r := ReportRecord{CheckID: check, Namespace: "default", OwnerKind: "Deployment", OwnerName: owner, Pod: owner + "-7d9c5b6f4b-x2k8p", Container: container}
+		r.Fingerprint = Fingerprint(r)
+		return r
+	}
+	privileged := record("privileged", "web", "app")
+	hostPath := record("hostpath", "web", "app")
+	hostPID := record("hostpid", "agent", "")
+	sidecar := record("privileged", "web", "sidecar")
+
+	// 滚动更新后 Pod 名称变化，旧报告中没有 fingerprint 字段
+	rolled := privileged
+	rolled.Pod = "web-5c8f9d7b6-m4n7q"
+	rolled.Fingerprint = ""
+	replica := hostPID
+	replica.Pod = "agent-q8m2z"
+
+	before := Report{Findings: []ReportRecord{privileged, hostPath}}
+	after := Report{
+		Findings:   []ReportRecord{rolled, hostPID, replica, sidecar},
+		Suppressed: []ReportRecord{hostPath},
+	}
+	diff := DiffReports(before, after)
+
+	names := func(records []ReportRecord) []string {
+		var got []string
+		for _, r := range records {
+			got = append(got, r.CheckID+"/"+r.Container)
+		}
+		return got
+	}
+	if got, want := names(diff.Introduced), []string{"hostpid/", "privileged/sidecar"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("introduced = %v, want %v", got, want)
+	}
+	// 被抑制的问题不参与比较，抑制后按已解决处理
+	if got, want := names(diff.Resolved), []string{"hostpath/app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resolved = %v, want %v", got, want)
+	}
+	if got, want := names(diff.Unchanged), []string{"privileged/app"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unchanged = %v, want %v", got, want)
+	}
+	if diff.Unchanged[0].Fingerprint != privileged.Fingerprint {
+		t.Errorf("unchanged fingerprint = %q, want it recomputed as %q", diff.Unchanged[0].Fingerprint, privileged.Fingerprint)
+	}
+
+	empty := DiffReports(Report{}, Report{})
+	if empty.Introduced == nil || empty.Resolved == nil || empty.Unchanged == nil {
+		t.Errorf("empty diff has nil sections: %+v", empty)
+	}
+}
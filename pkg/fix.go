@@ -0,0 +1,489 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFix 是对一个清单文件的修复
+type ManifestFix struct {
+	File     string
+	Original []byte
+	Fixed    []byte
+	Findings []Finding // 修复的问题
+}
+
+// Diff 返回修复前后的 unified diff
+func (m ManifestFix) Diff() (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(m.Original)),
+		B:        difflib.SplitLines(string(m.Fixed)),
+		FromFile: m.File,
+		ToFile:   m.File,
+		Context:  3,
+	})
+}
+
+// FixManifests 把可以自动修复的问题直接应用到问题所在的 YAML 文件，返回有修改的文件，文件按问题第一次出现的顺序排列。
+// 修改通过 yaml.v3 的节点 API 完成，保留注释和键的顺序，只重新生成包含问题的文档，其他文档和文档中没有修改的行保持原样。
+// 无法自动修复的问题、JSON 文件和标准输入中的问题在 manual 中返回
+func FixManifests(findings []Finding) (fixes []ManifestFix, manual []Finding, err error) {
+	var fixable []Finding
+	for _, f := range findings {
+		if f.Source == nil || f.Source.File == "-" || strings.EqualFold(filepath.Ext(f.Source.File), ".json") {
+			manual = append(manual, f)
+			continue
+		}
+		fixable = append(fixable, f)
+	}
+	patches, unfixable := BuildPatches(fixable)
+	manual = append(manual, unfixable...)
+
+	byFile := make(map[string][]WorkloadPatch)
+	var files []string
+	for _, p := range patches {
+		file := p.Target.Source.File
+		if _, ok := byFile[file]; !ok {
+			files = append(files, file)
+		}
+		byFile[file] = append(byFile[file], p)
+	}
+	for _, file := range files {
+		fix, err := fixManifestFile(file, byFile[file])
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", file, err)
+		}
+		fixes = append(fixes, fix)
+	}
+	return fixes, manual, nil
+}
+
+func fixManifestFile(file string, patches []WorkloadPatch) (ManifestFix, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return ManifestFix{}, err
+	}
+	docs, err := decodeDocuments(data)
+	if err != nil {
+		return ManifestFix{}, err
+	}
+	fix := ManifestFix{File: file, Original: data}
+	changed := make(map[int]bool)
+	for _, p := range patches {
+		src := p.Target.Source
+		if src.Document >= len(docs) || len(docs[src.Document].Content) == 0 {
+			return ManifestFix{}, fmt.Errorf("document %d not found", src.Document)
+		}
+		obj := findObjectNode(docs[src.Document].Content[0], src.Line)
+		if obj == nil {
+			return ManifestFix{}, fmt.Errorf("document %d: %s not found at line %d", src.Document, p.Target, src.Line)
+		}
+		for _, f := range p.Fixes {
+			if err := applyFix(obj, patchableKinds[p.Target.Kind].template, f); err != nil {
+				return ManifestFix{}, fmt.Errorf("document %d: %s: %w", src.Document, p.Target, err)
+			}
+		}
+		changed[src.Document] = true
+		fix.Findings = append(fix.Findings, p.Findings...)
+	}
+	if fix.Fixed, err = spliceDocuments(data, docs, changed); err != nil {
+		return ManifestFix{}, err
+	}
+	return fix, nil
+}
+
+// decodeDocuments 按 decodeManifests 相同的方式逐个解码文档，文档序号与 ManifestSource.Document 一致
+func decodeDocuments(data []byte) ([]*yaml.Node, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document %d: %w", len(docs), err)
+		}
+		docs = append(docs, &node)
+	}
+}
+
+// findObjectNode 返回文档中起始行为 line 的对象，List 中的对象按行号查找
+func findObjectNode(node *yaml.Node, line int) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	if node.Line == line {
+		return node
+	}
+	if items := mappingValue(node, "items"); items != nil && items.Kind == yaml.SequenceNode {
+		for _, item := range items.Content {
+			if obj := findObjectNode(item, line); obj != nil {
+				return obj
+			}
+		}
+	}
+	return nil
+}
+
+// applyFix 在对象的 Pod 模板中执行一处修改
+func applyFix(obj *yaml.Node, template []string, fix Fix) error {
+	target := obj
+	for _, key := range template {
+		target = ensureMapping(target, key)
+	}
+	if fix.Container != "" {
+		list := mappingValue(ensureMapping(target, "spec"), containerListKeys[fix.ContainerType])
+		if target = namedItem(list, fix.Container); target == nil {
+			return fmt.Errorf("%s %s not found", fix.ContainerType, fix.Container)
+		}
+	}
+	setNodeValue(target, fix.Path, fix.Value, fix.Remove)
+	return nil
+}
+
+// setNodeValue 把 path 处的字段设置为 value，value 为 nil 时删除字段，remove 不为空时从列表中删除这些元素。
+// 中间不存在的映射会被创建，删除不存在的字段时什么也不做，删除后变为空的映射也会被删除。已有字段上的注释会被保留
+func setNodeValue(m *yaml.Node, path []string, value any, remove []string) {
+	key := path[0]
+	existing := mappingValue(m, key)
+	if len(path) > 1 {
+		if value == nil || len(remove) > 0 {
+			if existing == nil || existing.Kind != yaml.MappingNode || len(existing.Content) == 0 {
+				return
+			}
+			setNodeValue(existing, path[1:], value, remove)
+			if len(existing.Content) == 0 {
+				deleteKey(m, key)
+			}
+			return
+		}
+		setNodeValue(ensureMapping(m, key), path[1:], value, remove)
+		return
+	}
+
+	switch v := value.(type) {
+	case nil:
+		if len(remove) == 0 {
+			deleteKey(m, key)
+			return
+		}
+		if existing == nil || existing.Kind != yaml.SequenceNode {
+			return
+		}
+		existing.Content = slices.DeleteFunc(existing.Content, func(item *yaml.Node) bool {
+			return slices.Contains(remove, item.Value)
+		})
+		if len(existing.Content) == 0 {
+			deleteKey(m, key)
+		}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k, child := range v {
+			if child != nil || existing != nil {
+				keys = append(keys, k)
+			}
+		}
+		if len(keys) == 0 {
+			return
+		}
+		slices.Sort(keys)
+		child := ensureMapping(m, key)
+		for _, k := range keys {
+			setNodeValue(child, []string{k}, v[k], nil)
+		}
+	default:
+		var node yaml.Node
+		if err := node.Encode(value); err != nil {
+			panic(err) // value 只会是布尔值、字符串和字符串列表
+		}
+		if existing == nil {
+			m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, &node)
+			return
+		}
+		node.HeadComment, node.LineComment, node.FootComment = existing.HeadComment, existing.LineComment, existing.FootComment
+		if node.Kind == existing.Kind {
+			node.Style = existing.Style &^ (yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle | yaml.TaggedStyle)
+		}
+		*existing = node
+	}
+}
+
+// mappingValue 返回映射节点中 key 对应的值，不存在时返回 nil
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// ensureMapping 返回 key 对应的映射节点，不存在或为 null 时创建
+func ensureMapping(m *yaml.Node, key string) *yaml.Node {
+	v := mappingValue(m, key)
+	if v == nil {
+		v = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		m.Content = append(m.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, v)
+	} else if v.Kind != yaml.MappingNode {
+		*v = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", HeadComment: v.HeadComment, LineComment: v.LineComment}
+	}
+	return v
+}
+
+func deleteKey(m *yaml.Node, key string) {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			m.Content = slices.Delete(m.Content, i, i+2)
+			return
+		}
+	}
+}
+
+// namedItem 返回列表中 name 为指定值的映射，例如按名称查找容器
+func namedItem(list *yaml.Node, name string) *yaml.Node {
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for _, item := range list.Content {
+		if v := mappingValue(item, "name"); v != nil && v.Value == name {
+			return item
+		}
+	}
+	return nil
+}
+
+var documentSeparator = regexp.MustCompile(`^---(\s|$)`)
+
+// spliceDocuments 用重新编码的文档替换原文中对应的行，没有修改的文档、文档分隔符和文档之间的空行保持原样
+func spliceDocuments(data []byte, docs []*yaml.Node, changed map[int]bool) ([]byte, error) {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	var separators []int
+	for i, line := range lines {
+		if documentSeparator.MatchString(line) {
+			separators = append(separators, i)
+		}
+	}
+
+	var out strings.Builder
+	next := 0
+	for i, doc := range docs {
+		if !changed[i] {
+			continue
+		}
+		// 文档占据上一个分隔符之后到下一个分隔符之前的行，分隔符和内容在同一行时从分隔符开始
+		first := doc.Content[0].Line - 1
+		start, end, prefix := 0, len(lines), ""
+		for _, s := range separators {
+			switch {
+			case s < first:
+				start = s + 1
+			case s == first:
+				start, prefix = s, "---\n"
+			case end == len(lines):
+				end = s
+			}
+		}
+		trailing := 0
+		for end-trailing > start && strings.TrimSpace(lines[end-trailing-1]) == "" {
+			trailing++
+		}
+
+		var buf bytes.Buffer
+		if err := encodeYAML(&buf, doc); err != nil {
+			return nil, fmt.Errorf("document %d: %w", i, err)
+		}
+		encoded := buf.String()
+		if compactSequences(lines[start:end]) {
+			encoded = compactSequenceIndent(encoded)
+		}
+		encoded = keepOriginalLines(lines[start:end-trailing], encoded)
+		out.WriteString(strings.Join(lines[next:start], ""))
+		out.WriteString(prefix)
+		out.WriteString(encoded)
+		out.WriteString(strings.Repeat("\n", trailing))
+		next = end
+	}
+	out.WriteString(strings.Join(lines[next:], ""))
+	return []byte(out.String()), nil
+}
+
+// sequenceItem 匹配块序列的元素，mappingKey 匹配值在下一行的映射键
+var (
+	sequenceItem = regexp.MustCompile(`^(\s*)-(\s|$)`)
+	mappingKey   = regexp.MustCompile(`^(\s*)[^\s#-][^#]*:\s*(#.*)?$`)
+)
+
+// compactSequences 判断原文是否把映射中的序列写成与键相同的缩进(kubectl 和 Helm 的风格)
+func compactSequences(lines []string) bool {
+	keyIndent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		if m := sequenceItem.FindStringSubmatch(line); m != nil && keyIndent >= 0 {
+			return len(m[1]) == keyIndent
+		}
+		keyIndent = -1
+		if m := mappingKey.FindStringSubmatch(line); m != nil {
+			keyIndent = len(m[1])
+		}
+	}
+	return false
+}
+
+// compactSequenceIndent 把 yaml.v3 缩进的序列改为与键相同的缩进。结果与原文的内容不一致时(例如块标量中的文本被误判)
+// 返回原文
+func compactSequenceIndent(text string) string {
+	lines := strings.SplitAfter(text, "\n")
+	var open []int // 正在输出的序列中元素的缩进
+	keyIndent := -1
+	for i, line := range lines {
+		trimmed := strings.TrimLeft(line, " ")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := len(line) - len(trimmed)
+		for len(open) > 0 && indent < open[len(open)-1] {
+			open = open[:len(open)-1]
+		}
+		if sequenceItem.MatchString(line) && indent == keyIndent+2 && (len(open) == 0 || open[len(open)-1] != indent) {
+			open = append(open, indent)
+		}
+		keyIndent = -1
+		if mappingKey.MatchString(line) {
+			keyIndent = indent
+		}
+		lines[i] = strings.Repeat(" ", indent-2*len(open)) + trimmed
+	}
+	compacted := strings.Join(lines, "")
+
+	var before, after any
+	if yaml.Unmarshal([]byte(text), &before) != nil || yaml.Unmarshal([]byte(compacted), &after) != nil || !reflect.DeepEqual(before, after) {
+		return text
+	}
+	return compacted
+}
+
+// inlineCommentSpacing 匹配行内注释前的空白，yaml.v3 重新编码时会把它改为一个空格
+var inlineCommentSpacing = regexp.MustCompile(`(\S)\s+#`)
+
+// normalizeLine 去掉重新编码会改变的空白，用于判断一行是否被修改
+func normalizeLine(line string) string {
+	return inlineCommentSpacing.ReplaceAllString(strings.TrimRight(line, " \t\r\n"), "$1 #")
+}
+
+// keepOriginalLines 对比原文和重新编码的文档，没有修改的行使用原文，保留行内注释前的对齐空白和 CRLF 换行，
+// 并恢复 yaml.v3 丢弃的空行。结果与重新编码的内容不一致时返回重新编码的文档
+func keepOriginalLines(original []string, encoded string) string {
+	lines := strings.SplitAfter(encoded, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	a := make([]string, len(original))
+	for i, line := range original {
+		a[i] = normalizeLine(line)
+	}
+	b := make([]string, len(lines))
+	for i, line := range lines {
+		b[i] = normalizeLine(line)
+	}
+
+	var out strings.Builder
+	for _, op := range difflib.NewMatcherWithJunk(a, b, false, nil).GetOpCodes() {
+		switch op.Tag {
+		case 'e':
+			out.WriteString(strings.Join(original[op.I1:op.I2], ""))
+		case 'd':
+			for _, line := range original[op.I1:op.I2] {
+				if strings.TrimSpace(line) == "" {
+					out.WriteString(line)
+				}
+			}
+		default:
+			// 修改过的行按原文对齐行内注释
+			for k, line := range lines[op.J1:op.J2] {
+				if op.Tag == 'r' && op.I1+k < op.I2 {
+					line = alignInlineComment(line, original[op.I1+k])
+				}
+				out.WriteString(line)
+			}
+		}
+	}
+	kept := out.String()
+	if !strings.HasSuffix(kept, "\n") {
+		kept += "\n"
+	}
+
+	var before, after any
+	if yaml.Unmarshal([]byte(encoded), &before) != nil || yaml.Unmarshal([]byte(kept), &after) != nil || !reflect.DeepEqual(before, after) {
+		return encoded
+	}
+	return kept
+}
+
+// fieldPrefix 匹配行首的缩进、列表标记和映射键
+var fieldPrefix = regexp.MustCompile(`^\s*(-\s+)?[^\s:#][^:#]*:`)
+
+// alignInlineComment 在同一字段被修改时，把新行的行内注释移到原文中注释所在的列
+func alignInlineComment(line, original string) string {
+	key := fieldPrefix.FindString(line)
+	if key == "" || key != fieldPrefix.FindString(original) {
+		return line
+	}
+	at := inlineCommentSpacing.FindStringIndex(line)
+	was := inlineCommentSpacing.FindStringIndex(original)
+	if at == nil || was == nil {
+		return line
+	}
+	content := line[:at[0]+1]
+	column := utf8.RuneCountInString(original[:was[1]-1])
+	pad := max(column-utf8.RuneCountInString(content), 1)
+	return content + strings.Repeat(" ", pad) + line[at[1]-1:]
+}
+
+// fixKey 按对象、检查和容器标识一个被修复的问题。Value 和 capabilities 等具体内容在修复后可能变化，
+// 不参与比较，修复后同一容器上仍有同一检查的问题就视为没有修复
+func fixKey(f Finding) string {
+	return strings.Join([]string{FindingObject(f).key(), f.Check, f.ContainerType, f.Container}, "|")
+}
+
+// VerifyFixes 对修复后的内容重新执行检查，返回仍然存在的被修复的问题
+func VerifyFixes(fixes []ManifestFix, checks []Check) ([]Finding, error) {
+	var remaining []Finding
+	for _, fix := range fixes {
+		objects, err := decodeManifests(bytes.NewReader(fix.Fixed), fix.File)
+		if err != nil {
+			return nil, err
+		}
+		found := make(map[string]bool)
+		for _, f := range ScanManifests(objects, checks).Findings {
+			found[fixKey(f)] = true
+		}
+		for _, f := range fix.Findings {
+			if found[fixKey(f)] {
+				remaining = append(remaining, f)
+			}
+		}
+	}
+	return remaining, nil
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "重新生成 testdata 中的 golden 文件")
+
+// splitDocuments 按文档分隔符切分原文，每段包含分隔符之前的行
+func splitDocuments(data []byte) []string {
+	var docs []string
+	var cur strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if documentSeparator.MatchString(line) {
+			docs = append(docs, cur.String())
+			cur.Reset()
+		}
+		cur.WriteString(line)
+	}
+	return append(docs, cur.String())
+}
+
+func TestFixManifestsGolden(t *testing.T) {
+	inputs, err := filepath.Glob(filepath.Join("testdata", "fix", "*.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("no test manifests")
+	}
+	checks := ChecksForProfile(LevelBaseline)
+	for _, input := range inputs {
+		name := strings.TrimSuffix(filepath.Base(input), ".yaml")
+		t.Run(name, func(t *testing.T) {
+			original, err := os.ReadFile(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(t.TempDir(), name+".yaml")
+			if err := os.WriteFile(file, original, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			objects, err := LoadManifests([]string{file}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fixes, manual, err := FixManifests(ScanManifests(objects, checks).Findings)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(manual) > 0 {
+				t.Errorf("%d findings need manual fixes, want 0: %v", len(manual), manual)
+			}
+			if len(fixes) != 1 {
+				t.Fatalf("got %d fixed files, want 1", len(fixes))
+			}
+			remaining, err := VerifyFixes(fixes, checks)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(remaining) > 0 {
+				t.Errorf("findings remain after fixing: %v", remaining)
+			}
+
+			fixed := fixes[0].Fixed
+			golden := strings.TrimSuffix(input, ".yaml") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, fixed, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(fixed, want) {
+				diff, _ := ManifestFix{File: golden, Original: want, Fixed: fixed}.Diff()
+				t.Errorf("fixed manifest differs from %s:\n%s", golden, diff)
+			}
+
+			// 没有问题的文档必须逐字节保持原样
+			changed := make(map[int]bool)
+			for _, f := range fixes[0].Findings {
+				changed[f.Source.Document] = true
+			}
+			before, after := splitDocuments(original), splitDocuments(fixed)
+			if len(before) != len(after) {
+				t.Fatalf("got %d documents, want %d", len(after), len(before))
+			}
+			for i := range before {
+				if !changed[i] && before[i] != after[i] {
+					t.Errorf("unchanged document %d was rewritten:\n%q\nwant:\n%q", i, after[i], before[i])
+				}
+			}
+		})
+	}
+}
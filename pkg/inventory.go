@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ScannedObject 是一次扫描中检查过的对象：顶层控制器、清单中的对象或没有控制器的 Pod
+type ScannedObject struct {
+	Cluster    string
+	Namespace  string
+	Kind       string
+	Name       string
+	Pods       int // 集群扫描时属于该对象的 Pod 数量
+	Source     *ManifestSource
+	Containers []ScannedContainer // 对象中检查过的容器，控制器的多个 Pod 中出现过的容器都会列出
+}
+
+// ScannedContainer 是检查过的容器
+type ScannedContainer struct {
+	Name string
+	Type string // container、initContainer 或 ephemeralContainer
+}
+
+func (c ScannedContainer) String() string {
+	return c.Type + " " + c.Name
+}
+
+// podContainers 按 visitContainers 的顺序返回 Pod 中的容器
+func podContainers(pod *corev1.Pod) []ScannedContainer {
+	var containers []ScannedContainer
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		containers = append(containers, ScannedContainer{Name: container.Name, Type: containerType})
+	})
+	return containers
+}
+
+// addContainers 把 pod 中还没有记录的容器追加到对象中，例如只在部分 Pod 中存在的临时容器
+func (o *ScannedObject) addContainers(pod *corev1.Pod) {
+	for _, c := range podContainers(pod) {
+		if !slices.Contains(o.Containers, c) {
+			o.Containers = append(o.Containers, c)
+		}
+	}
+}
+
+// ScanResult 是一次扫描的结果：发现的问题和检查过的全部对象，没有问题的对象也会出现在 Objects 中
+type ScanResult struct {
+	Findings            []Finding
+	Suppressed          []Finding     // 被抑制规则匹配的问题，见 ApplySuppressions
+	ExpiredSuppressions []Suppression // 已过期的抑制规则
+	Objects             []ScannedObject
+	Pods                int     // 检查过的 Pod 或 Pod 模板数量
+	Checks              []Check // 本次扫描执行的检查，为空表示所有已注册的检查
+}
+
+// Merge 把另一个扫描结果追加到 r
+func (r *ScanResult) Merge(other ScanResult) {
+	r.Findings = append(r.Findings, other.Findings...)
+	r.Suppressed = append(r.Suppressed, other.Suppressed...)
+	r.ExpiredSuppressions = append(r.ExpiredSuppressions, other.ExpiredSuppressions...)
+	r.Objects = append(r.Objects, other.Objects...)
+	r.Pods += other.Pods
+	if r.Checks == nil {
+		r.Checks = other.Checks
+	}
+}
+
+// checks 返回报告中需要列出的检查
+func (r *ScanResult) checks() []Check {
+	if r.Checks == nil {
+		return Checks()
+	}
+	return r.Checks
+}
+
+// TagCluster 在问题和扫描对象中记录所属集群
+func (r *ScanResult) TagCluster(cluster string) {
+	TagCluster(r.Findings, cluster)
+	TagCluster(r.Suppressed, cluster)
+	for i := range r.Objects {
+		r.Objects[i].Cluster = cluster
+	}
+}
+
+// String 返回对象的显示名称，例如 prod/Deployment/web
+func (o ScannedObject) String() string {
+	name := o.Kind + "/" + o.Name
+	if o.Namespace != "" {
+		name = o.Namespace + "/" + name
+	}
+	if o.Cluster != "" {
+		name = o.Cluster + "/" + name
+	}
+	return name
+}
+
+func (o ScannedObject) key() string {
+	key := o.String()
+	if o.Source != nil {
+		key += fmt.Sprintf("@%s#%d", o.Source.File, o.Source.Document)
+	}
+	return key
+}
+
+// FindingObject 返回问题所属的扫描对象，与扫描时记录的对象一一对应
+func FindingObject(f Finding) ScannedObject {
+	o := ScannedObject{Cluster: f.Cluster, Namespace: f.Namespace, Kind: "Pod", Name: f.Pod, Source: f.Source}
+	switch {
+	case f.OwnerKind != "":
+		o.Kind, o.Name = f.OwnerKind, f.OwnerName
+	case f.Kind != "":
+		o.Kind = f.Kind
+	}
+	return o
+}
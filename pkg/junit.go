@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitPodCase 是每个对象中 Pod 级别问题(例如 hostPID 或所有容器都覆盖了的 Pod 级别设置)的 testcase 名称
+const junitPodCase = "pod"
+
+// WriteJUnit 把扫描结果写为 JUnit XML：每个执行过的检查是一个 testsuite，每个检查过的对象
+// (顶层控制器、清单对象或独立 Pod)的每个容器是其中的一个 testcase，classname 为对象，
+// Pod 级别的问题记录在名为 pod 的 testcase 中，存在问题时 failure 中列出具体内容和修复建议。
+// INFO 级别的问题和 result.Suppressed 中被抑制的问题不算失败，只写入 system-out
+func WriteJUnit(w io.Writer, result ScanResult) error {
+	// 按检查、对象和容器分组问题，Pod 级别的问题容器为空
+	grouped := make(map[string][]Finding)
+	for _, f := range append(append([]Finding(nil), result.Findings...), result.Suppressed...) {
+		key := junitKey(f.Check, FindingObject(f), ScannedContainer{Name: f.Container, Type: f.ContainerType})
+		grouped[key] = append(grouped[key], f)
+	}
+
+	report := junitTestSuites{Name: "getNoPSS"}
+	for _, c := range result.checks() {
+		suite := junitTestSuite{Name: c.Title()}
+		for _, obj := range result.Objects {
+			for _, container := range append([]ScannedContainer{{}}, obj.Containers...) {
+				tc := junitCase(c, obj, container, grouped[junitKey(c.ID(), obj, container)])
+				if tc.Failure != nil {
+					suite.Failures++
+				}
+				suite.Cases = append(suite.Cases, tc)
+			}
+		}
+		suite.Tests = len(suite.Cases)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Suites = append(report.Suites, suite)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func junitKey(check string, obj ScannedObject, container ScannedContainer) string {
+	return check + "|" + obj.key() + "|" + container.Type + "/" + container.Name
+}
+
+// junitCase 返回对象中一个容器的 testcase，container 为零值时是 Pod 级别的 testcase
+func junitCase(c Check, obj ScannedObject, container ScannedContainer, findings []Finding) junitTestCase {
+	tc := junitTestCase{Name: junitPodCase, ClassName: obj.String()}
+	if container.Name != "" {
+		tc.Name = container.String()
+	}
+	if obj.Source != nil && obj.Source.File != "-" {
+		tc.File, tc.Line = obj.Source.File, obj.Source.Line
+	}
+	var failed, info []string
+	severity := SeverityInfo
+	for _, f := range findings {
+		if f.Suppressed() || f.Severity.Rank() <= SeverityInfo.Rank() {
+			info = append(info, FormatFinding(f))
+			continue
+		}
+		failed = append(failed, FormatFinding(f))
+		if f.Severity.Rank() > severity.Rank() {
+			severity = f.Severity
+		}
+	}
+	if len(failed) > 0 {
+		tc.Failure = &junitFailure{
+			Message: fmt.Sprintf("%s: %d finding(s)", c.Title(), len(failed)),
+			Type:    string(severity),
+			Text:    strings.Join(append(failed, "", c.Description(), "修复建议: "+c.Remediation()), "\n"),
+		}
+	}
+	tc.SystemOut = strings.Join(info, "\n")
+	return tc
+}
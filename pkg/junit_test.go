@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	hostPID, _ := LookupCheck("hostpid")
+	privileged, _ := LookupCheck("privileged")
+	web := ScannedObject{Namespace: "default", Kind: "Deployment", Name: "web", Pods: 2, Containers: []ScannedContainer{
+		{Name: "app", Type: ContainerTypeContainer},
+		{Name: "sidecar", Type: ContainerTypeContainer},
+		{Name: "init", Type: ContainerTypeInit},
+	}}
+	debug := ScannedObject{Namespace: "default", Kind: "Pod", Name: "debug", Pods: 1, Containers: []ScannedContainer{
+		{Name: "shell", Type: ContainerTypeContainer},
+	}}
+	webFinding := func(check, container, containerType string, severity Severity) Finding {
+		return Finding{Check: check, Severity: severity, Namespace: "default", Pod: "web-7d9c5b6f4b-x2k8p",
+			OwnerKind: "Deployment", OwnerName: "web", Container: container, ContainerType: containerType}
+	}
+	result := ScanResult{
+		Checks:  []Check{hostPID, privileged},
+		Objects: []ScannedObject{web, debug},
+		Findings: []Finding{
+			webFinding("hostpid", "", "", SeverityHigh),
+			webFinding("privileged", "app", ContainerTypeContainer, SeverityCritical),
+			webFinding("privileged", "init", ContainerTypeInit, SeverityInfo),
+		},
+		Suppressed: []Finding{{
+			Check: "privileged", Severity: SeverityCritical, Namespace: "default", Pod: "debug", Container: "shell", ContainerType: ContainerTypeContainer,
+			Suppression: &Suppression{Check: "privileged", Source: SuppressionSourceAnnotation},
+		}},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, result); err != nil {
+		t.Fatal(err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, buf.String())
+	}
+
+	// testcase 用 "classname name [failure 类型] [system-out]" 表示
+	summary := func(suite junitTestSuite) []string {
+		var got []string
+		for _, tc := range suite.Cases {
+			line := tc.ClassName + " " + tc.Name
+			if tc.Failure != nil {
+				line += " failure " + tc.Failure.Type
+			}
+			if tc.SystemOut != "" {
+				line += " system-out"
+			}
+			got = append(got, line)
+		}
+		return got
+	}
+	want := map[string][]string{
+		"Host PID": {
+			"default/Deployment/web pod failure HIGH",
+			"default/Deployment/web container app",
+			"default/Deployment/web container sidecar",
+			"default/Deployment/web initContainer init",
+			"default/Pod/debug pod",
+			"default/Pod/debug container shell",
+		},
+		"Privileged Container": {
+			"default/Deployment/web pod",
+			"default/Deployment/web container app failure CRITICAL",
+			"default/Deployment/web container sidecar",
+			"default/Deployment/web initContainer init system-out",
+			"default/Pod/debug pod",
+			"default/Pod/debug container shell system-out",
+		},
+	}
+	if len(report.Suites) != 2 {
+		t.Fatalf("got %d testsuites, want 2", len(report.Suites))
+	}
+	for _, suite := range report.Suites {
+		if got := summary(suite); !reflect.DeepEqual(got, want[suite.Name]) {
+			t.Errorf("testsuite %s:\n%s\nwant:\n%s", suite.Name, strings.Join(got, "\n"), strings.Join(want[suite.Name], "\n"))
+		}
+		if suite.Tests != 6 || suite.Failures != 1 {
+			t.Errorf("testsuite %s has tests=%d failures=%d, want 6 and 1", suite.Name, suite.Tests, suite.Failures)
+		}
+	}
+	if report.Tests != 12 || report.Failures != 2 {
+		t.Errorf("testsuites has tests=%d failures=%d, want 12 and 2", report.Tests, report.Failures)
+	}
+
+	failure := report.Suites[1].Cases[1].Failure
+	for _, part := range []string{"Privileged Container: 1 finding(s)", "container app", "修复建议: " + privileged.Remediation()} {
+		if !strings.Contains(failure.Message+"\n"+failure.Text, part) {
+			t.Errorf("failure is missing %q:\n%s\n%s", part, failure.Message, failure.Text)
+		}
+	}
+	if strings.Contains(failure.Text, "container init") {
+		t.Errorf("failure for container app lists another container:\n%s", failure.Text)
+	}
+}
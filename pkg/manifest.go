@@ -0,0 +1,255 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ManifestSource 记录对象在清单文件中的位置
+type ManifestSource struct {
+	File     string `json:"file"`           // 文件路径，标准输入为 "-"
+	Document int    `json:"document"`       // 文件中的 YAML 文档序号，从 0 开始
+	Line     int    `json:"line,omitempty"` // 对象在文件中的起始行
+}
+
+// ManifestObject 是从清单中提取出的一个 Pod，或工作负载中的 Pod 模板
+type ManifestObject struct {
+	Kind   string
+	Source ManifestSource
+	Pod    corev1.Pod
+}
+
+// LoadManifests 从文件、目录(递归)或标准输入("-")读取多文档 YAML/JSON 清单，并提取其中的 Pod 定义
+func LoadManifests(paths []string, stdin io.Reader) ([]ManifestObject, error) {
+	var objects []ManifestObject
+	for _, path := range paths {
+		if path == "-" {
+			objs, err := decodeManifests(stdin, "-")
+			if err != nil {
+				return nil, err
+			}
+			objects = append(objects, objs...)
+			continue
+		}
+		err := filepath.WalkDir(path, func(file string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			// 目录中只读取清单文件，显式指定的文件不限制扩展名
+			if file != path && !isManifestFile(file) {
+				return nil
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			objs, err := decodeManifests(f, file)
+			if err != nil {
+				return err
+			}
+			objects = append(objects, objs...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
+
+func isManifestFile(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+func decodeManifests(r io.Reader, file string) ([]ManifestObject, error) {
+	var objects []ManifestObject
+	dec := yaml.NewDecoder(r)
+	for doc := 0; ; doc++ {
+		var node yaml.Node
+		err := dec.Decode(&node)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", file, doc, err)
+		}
+		if len(node.Content) == 0 {
+			continue
+		}
+		objs, err := extractFromNode(node.Content[0], ManifestSource{File: file, Document: doc})
+		if err != nil {
+			return nil, fmt.Errorf("%s: document %d: %w", file, doc, err)
+		}
+		objects = append(objects, objs...)
+	}
+	return objects, nil
+}
+
+func extractFromNode(node *yaml.Node, src ManifestSource) ([]ManifestObject, error) {
+	if node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	src.Line = node.Line
+
+	var raw interface{}
+	if err := node.Decode(&raw); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var meta metav1.TypeMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+
+	// List 类型逐项展开，每一项使用自己所在的行号
+	if isListKind(meta) {
+		var objects []ManifestObject
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value != "items" || node.Content[i+1].Kind != yaml.SequenceNode {
+				continue
+			}
+			for _, item := range node.Content[i+1].Content {
+				objs, err := extractFromNode(item, src)
+				if err != nil {
+					return nil, err
+				}
+				objects = append(objects, objs...)
+			}
+		}
+		return objects, nil
+	}
+
+	pod, ok, err := PodFromObject(meta.Kind, data)
+	if err != nil {
+		return nil, fmt.Errorf("decode %s: %w", meta.Kind, err)
+	}
+	if !ok {
+		// ConfigMap、Service 等不包含 Pod 定义的对象直接跳过
+		return nil, nil
+	}
+	return []ManifestObject{{Kind: meta.Kind, Source: src, Pod: *pod}}, nil
+}
+
+// listKinds 是会逐项展开的列表类型及其 API 组，CRD 中以 List 结尾的类型(例如 AllowList)不是列表
+var listKinds = map[string]string{
+	"List":            "",
+	"PodList":         "",
+	"DeploymentList":  "apps",
+	"StatefulSetList": "apps",
+	"DaemonSetList":   "apps",
+	"ReplicaSetList":  "apps",
+	"JobList":         "batch",
+	"CronJobList":     "batch",
+}
+
+// isListKind 判断对象是否为包含 items 的列表
+func isListKind(meta metav1.TypeMeta) bool {
+	group, ok := listKinds[meta.Kind]
+	if !ok {
+		return false
+	}
+	gv, err := schema.ParseGroupVersion(meta.APIVersion)
+	return err == nil && gv.Group == group
+}
+
+// PodFromObject 从 Pod 或工作负载对象的 JSON 中提取 Pod 定义，工作负载使用其 Pod 模板并沿用工作负载的名称和命名空间。
+// 不包含 Pod 定义的类型返回 ok=false
+func PodFromObject(kind string, data []byte) (pod *corev1.Pod, ok bool, err error) {
+	decode := func(obj interface{}) error {
+		return json.Unmarshal(data, obj)
+	}
+	switch kind {
+	case "Pod":
+		var p corev1.Pod
+		if err := decode(&p); err != nil {
+			return nil, false, err
+		}
+		return &p, true, nil
+	case "Deployment":
+		var d appsv1.Deployment
+		if err := decode(&d); err != nil {
+			return nil, false, err
+		}
+		return templatePod(d.ObjectMeta, d.Spec.Template), true, nil
+	case "StatefulSet":
+		var s appsv1.StatefulSet
+		if err := decode(&s); err != nil {
+			return nil, false, err
+		}
+		return templatePod(s.ObjectMeta, s.Spec.Template), true, nil
+	case "DaemonSet":
+		var d appsv1.DaemonSet
+		if err := decode(&d); err != nil {
+			return nil, false, err
+		}
+		return templatePod(d.ObjectMeta, d.Spec.Template), true, nil
+	case "ReplicaSet":
+		var r appsv1.ReplicaSet
+		if err := decode(&r); err != nil {
+			return nil, false, err
+		}
+		return templatePod(r.ObjectMeta, r.Spec.Template), true, nil
+	case "Job":
+		var j batchv1.Job
+		if err := decode(&j); err != nil {
+			return nil, false, err
+		}
+		return templatePod(j.ObjectMeta, j.Spec.Template), true, nil
+	case "CronJob":
+		var c batchv1.CronJob
+		if err := decode(&c); err != nil {
+			return nil, false, err
+		}
+		return templatePod(c.ObjectMeta, c.Spec.JobTemplate.Spec.Template), true, nil
+	}
+	return nil, false, nil
+}
+
+func templatePod(meta metav1.ObjectMeta, template corev1.PodTemplateSpec) *corev1.Pod {
+	pod := &corev1.Pod{ObjectMeta: template.ObjectMeta, Spec: template.Spec}
+	pod.Name = meta.Name
+	pod.Namespace = meta.Namespace
+	return pod
+}
+
+// ScanManifests 对清单中提取出的 Pod 执行检查，并在结果中记录对象的类型和来源位置
+func ScanManifests(objects []ManifestObject, checks []Check) ScanResult {
+	result := ScanResult{Checks: checks}
+	for i := range objects {
+		obj := &objects[i]
+		src := obj.Source
+		result.Objects = append(result.Objects, ScannedObject{
+			Namespace: obj.Pod.Namespace, Kind: obj.Kind, Name: obj.Pod.Name, Source: &src, Containers: podContainers(&obj.Pod),
+		})
+		result.Pods++
+		for _, f := range EvaluatePod(&obj.Pod, checks) {
+			f.Kind = obj.Kind
+			f.Source = &src
+			result.Findings = append(result.Findings, f)
+		}
+	}
+	return result
+}
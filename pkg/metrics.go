@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+var (
+	metricsRegistry = prometheus.NewRegistry()
+
+	findingsGauge = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "getnopss_findings",
+		Help: "Number of pods with a finding, by check, namespace and severity.",
+	}, []string{"check", "namespace", "severity"})
+
+	podsScannedGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "getnopss_pods_scanned",
+		Help: "Number of pods evaluated in the latest scan.",
+	})
+
+	scanDurationHistogram = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "getnopss_scan_duration_seconds",
+		Help:    "Duration of a full scan, from listing pods to evaluating all checks.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 12),
+	})
+
+	aiAnalysesCounter = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "getnopss_ai_analyses_total",
+		Help: "Number of completed AI analyses, by security level.",
+	}, []string{"security_level"})
+
+	aiAnalysisErrorsCounter = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "getnopss_ai_analysis_errors_total",
+		Help: "Number of AI analyses that failed.",
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		findingsGauge,
+		podsScannedGauge,
+		scanDurationHistogram,
+		aiAnalysesCounter,
+		aiAnalysisErrorsCounter,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// MetricsHandler 返回暴露 Prometheus 指标的 HTTP 处理器
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// RecordFindings 用当前的全部问题重置 getnopss_findings，合并后的问题按副本数计数，通过注解豁免的问题不计入
+func RecordFindings(findings []Finding) {
+	findingsGauge.Reset()
+	for _, f := range findings {
+		if f.Suppressed() {
+			continue
+		}
+		count := f.Replicas
+		if count < 1 {
+			count = 1
+		}
+		findingsGauge.WithLabelValues(f.Check, f.Namespace, string(f.Severity)).Add(float64(count))
+	}
+}
+
+// RecordScan 记录一次完整扫描的 Pod 数量和耗时
+func RecordScan(pods int, duration time.Duration) {
+	podsScannedGauge.Set(float64(pods))
+	scanDurationHistogram.Observe(duration.Seconds())
+}
+
+// RecordPodsScanned 更新当前检查的 Pod 数量，用于没有完整扫描周期的 watch 模式
+func RecordPodsScanned(pods int) {
+	podsScannedGauge.Set(float64(pods))
+}
+
+// PushAIMetrics 把 AI 分析计数推送到 Pushgateway。aiAnalysis 是一次性命令，进程结束后 /metrics 无法再被抓取
+func PushAIMetrics(url string) error {
+	return push.New(url, "getnopss_ai_analysis").
+		Collector(aiAnalysesCounter).
+		Collector(aiAnalysisErrorsCounter).
+		Push()
+}
+
+func recordAIAnalysis(securityLevel string) {
+	aiAnalysesCounter.WithLabelValues(securityLevel).Inc()
+}
+
+func recordAIAnalysisError() {
+	aiAnalysisErrorsCounter.Inc()
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func scrape(t *testing.T, url string) string {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d, want 200", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestAIAnalysisMetrics(t *testing.T) {
+	aiAnalysesCounter.Reset()
+	levels := map[string]int{"SAFE": 3, "MODERATE": 2, "HIGH_RISK": 1, "CRITICAL": 4, "UNKNOWN": 1}
+	for level, n := range levels {
+		for i := 0; i < n; i++ {
+			recordAIAnalysis(level)
+		}
+	}
+	before := scrape(t, serveMetrics(t))
+	recordAIAnalysisError()
+	recordAIAnalysisError()
+	body := scrape(t, serveMetrics(t))
+
+	for level, n := range levels {
+		want := `getnopss_ai_analyses_total{security_level="` + level + `"} ` + strconv.Itoa(n)
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("metrics do not contain %q", want)
+		}
+	}
+	if got, want := metricValue(t, body, "getnopss_ai_analysis_errors_total")-metricValue(t, before, "getnopss_ai_analysis_errors_total"), 2.0; got != want {
+		t.Errorf("errors increased by %v, want %v", got, want)
+	}
+}
+
+func TestPushAIMetrics(t *testing.T) {
+	aiAnalysesCounter.Reset()
+	recordAIAnalysis("CRITICAL")
+
+	var path, body string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, body = r.Method+" "+r.URL.Path, string(data)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+
+	if err := PushAIMetrics(gateway.URL); err != nil {
+		t.Fatal(err)
+	}
+	if want := "PUT /metrics/job/getnopss_ai_analysis"; path != want {
+		t.Errorf("request %q, want %q", path, want)
+	}
+	// 推送的是 protobuf 格式，只检查指标名称，且不包含扫描相关的指标
+	for _, want := range []string{"getnopss_ai_analyses_total", "CRITICAL", "getnopss_ai_analysis_errors_total"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("pushed body does not contain %q", want)
+		}
+	}
+	if strings.Contains(body, "getnopss_findings") {
+		t.Error("pushed body contains scan metrics")
+	}
+}
+
+func serveMetrics(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(MetricsHandler())
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func metricValue(t *testing.T, body, name string) float64 {
+	t.Helper()
+	for _, line := range strings.Split(body, "\n") {
+		if value, ok := strings.CutPrefix(line, name+" "); ok {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return v
+		}
+	}
+	t.Fatalf("metric %s not found", name)
+	return 0
+}
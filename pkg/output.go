@@ -7,6 +7,28 @@ import (
 	"time"
 )
 
+// AnalysisReport 是 aiAnalysis 保存的 JSON 报告
+type AnalysisReport struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	TotalPods   int            `json:"total_pods"`
+	Summary     map[string]int `json:"summary"`
+	Analyses    []AIAnalysis   `json:"analyses"`
+}
+
+// NewAnalysisReport 构造包含按安全等级统计的AI分析报告
+func NewAnalysisReport(analyses []AIAnalysis) AnalysisReport {
+	report := AnalysisReport{
+		GeneratedAt: time.Now(),
+		TotalPods:   len(analyses),
+		Summary:     make(map[string]int),
+		Analyses:    analyses,
+	}
+	for _, analysis := range analyses {
+		report.Summary[analysis.SecurityLevel]++
+	}
+	return report
+}
+
 func SaveAnalysisResultsAsHTML(analyses []AIAnalysis, filename string) error {
 	htmlContent := generateHTMLReport(analyses)
 	return os.WriteFile(filename, []byte(htmlContent), 0644)
@@ -87,10 +109,10 @@ func generateHTMLReport(analyses []AIAnalysis) string {
     <div class="pod-card">
         <div class="pod-header">
             <div>
-                <h3>%s / %s</h3>
+                <h3>%s</h3>
             </div>
             <span class="security-level level-%s">%s</span>
-        </div>`, analysis.Namespace, analysis.Pod, levelClass, analysis.SecurityLevel)
+        </div>`, strings.ReplaceAll(analysisSubject(analysis), "/", " / "), levelClass, analysis.SecurityLevel)
 
 		if len(analysis.Issues) > 0 {
 			html += `<div class="issues"><h4>🚨 发现的问题:</h4><ul>`
@@ -121,7 +143,7 @@ func PrintAnalysisToConsole(analyses []AIAnalysis) {
 	fmt.Println(strings.Repeat("=", 80))
 
 	for i, analysis := range analyses {
-		fmt.Printf("\n[%d/%d] Pod: %s/%s\n", i+1, len(analyses), analysis.Namespace, analysis.Pod)
+		fmt.Printf("\n[%d/%d] Pod: %s\n", i+1, len(analyses), analysisSubject(analysis))
 
 		// 安全等级用不同符号表示
 		var levelSymbol string
@@ -157,3 +179,12 @@ func PrintAnalysisToConsole(analyses []AIAnalysis) {
 		fmt.Println(strings.Repeat("-", 80))
 	}
 }
+
+// analysisSubject 返回分析对象的显示名称，多集群扫描时带上集群名称
+func analysisSubject(analysis AIAnalysis) string {
+	subject := analysis.Namespace + "/" + analysis.Pod
+	if analysis.Cluster != "" {
+		subject = analysis.Cluster + "/" + subject
+	}
+	return subject
+}
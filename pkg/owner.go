@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Owner 表示 Pod 的顶层控制器，例如 ReplicaSet 的所属 Deployment
+type Owner struct {
+	Kind string
+	Name string
+}
+
+// ownerCacheTTL 是 ReplicaSet 和 Job 控制器查询结果的缓存时间，长时间运行的 watch 模式下被删除或
+// 更换控制器的对象在过期后重新查询
+const ownerCacheTTL = 5 * time.Minute
+
+// OwnerResolver 沿 ownerReferences 向上查找 Pod 的顶层控制器，中间的 ReplicaSet 和 Job 查询结果会被缓存 ownerCacheTTL，
+// 查询失败的结果不缓存
+type OwnerResolver struct {
+	client    kubernetes.Interface
+	cache     map[string]ownerCacheEntry
+	lastSweep time.Time
+}
+
+type ownerCacheEntry struct {
+	ref     *metav1.OwnerReference
+	expires time.Time
+}
+
+func NewOwnerResolver(client kubernetes.Interface) *OwnerResolver {
+	return &OwnerResolver{client: client, cache: make(map[string]ownerCacheEntry), lastSweep: time.Now()}
+}
+
+// Resolve 返回 Pod 的顶层控制器，没有控制器的 Pod 返回空 Owner
+func (r *OwnerResolver) Resolve(ctx context.Context, pod *corev1.Pod) Owner {
+	ref := metav1.GetControllerOf(pod)
+	if ref == nil {
+		return Owner{}
+	}
+	owner := Owner{Kind: ref.Kind, Name: ref.Name}
+	// ReplicaSet→Deployment、Job→CronJob 需要再向上查找一层
+	if ref.Kind == "ReplicaSet" || ref.Kind == "Job" {
+		if parent := r.controllerOf(ctx, ref.Kind, pod.Namespace, ref.Name); parent != nil {
+			owner = Owner{Kind: parent.Kind, Name: parent.Name}
+		}
+	}
+	return owner
+}
+
+func (r *OwnerResolver) controllerOf(ctx context.Context, kind, namespace, name string) *metav1.OwnerReference {
+	key := kind + "/" + namespace + "/" + name
+	now := time.Now()
+	if entry, ok := r.cache[key]; ok && now.Before(entry.expires) {
+		return entry.ref
+	}
+	var meta metav1.Object
+	var err error
+	switch kind {
+	case "ReplicaSet":
+		meta, err = r.client.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+	case "Job":
+		meta, err = r.client.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+	}
+	if err != nil {
+		// 不缓存失败的查询，避免一次临时错误让该工作负载一直归属到 ReplicaSet 或 Job
+		log.Warn().Err(err).Msgf("查询 %s %s/%s 失败", kind, namespace, name)
+		delete(r.cache, key)
+		return nil
+	}
+	var ref *metav1.OwnerReference
+	if meta != nil {
+		ref = metav1.GetControllerOf(meta)
+	}
+	r.sweep(now)
+	r.cache[key] = ownerCacheEntry{ref: ref, expires: now.Add(ownerCacheTTL)}
+	return ref
+}
+
+// sweep 每隔 ownerCacheTTL 删除过期的缓存，缓存大小只与最近查询过的对象数量有关
+func (r *OwnerResolver) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < ownerCacheTTL {
+		return
+	}
+	for key, entry := range r.cache {
+		if !now.Before(entry.expires) {
+			delete(r.cache, key)
+		}
+	}
+	r.lastSweep = now
+}
+
+// PodScanner 逐页检查 Pod，把结果归属到顶层控制器并在扫描过程中合并同一工作负载下重复的问题，
+// 内存占用只与问题和工作负载的数量有关，与 Pod 数量无关
+type PodScanner struct {
+	resolver *OwnerResolver
+	checks   []Check
+	findings []Finding
+	index    map[string]int
+	objects  []ScannedObject
+	objIndex map[string]int
+	scanned  int
+}
+
+func NewPodScanner(client kubernetes.Interface, checks []Check) *PodScanner {
+	return &PodScanner{
+		resolver: NewOwnerResolver(client),
+		checks:   checks,
+		index:    make(map[string]int),
+		objIndex: make(map[string]int),
+	}
+}
+
+// Scan 检查一页 Pod
+func (s *PodScanner) Scan(pods []corev1.Pod) {
+	for i := range pods {
+		pod := &pods[i]
+		s.scanned++
+		owner := s.resolver.Resolve(context.TODO(), pod)
+		s.addObject(pod, owner)
+		for _, f := range EvaluatePod(pod, s.checks) {
+			f.OwnerKind = owner.Kind
+			f.OwnerName = owner.Name
+			s.add(f)
+		}
+	}
+}
+
+// addObject 记录检查过的对象，有控制器的 Pod 计入控制器
+func (s *PodScanner) addObject(pod *corev1.Pod, owner Owner) {
+	obj := ScannedObject{Namespace: pod.Namespace, Kind: "Pod", Name: pod.Name, Pods: 1}
+	if owner.Kind != "" {
+		obj.Kind, obj.Name = owner.Kind, owner.Name
+	}
+	key := obj.key()
+	if i, ok := s.objIndex[key]; ok {
+		s.objects[i].Pods++
+		s.objects[i].addContainers(pod)
+		return
+	}
+	obj.Containers = podContainers(pod)
+	s.objIndex[key] = len(s.objects)
+	s.objects = append(s.objects, obj)
+}
+
+func (s *PodScanner) add(f Finding) {
+	if f.OwnerKind == "" {
+		s.findings = append(s.findings, f)
+		return
+	}
+	key := f.OwnerKind + "/" + f.OwnerName + "|" + f.contentKey()
+	if i, ok := s.index[key]; ok {
+		s.findings[i].Replicas++
+		return
+	}
+	f.Replicas = 1
+	s.index[key] = len(s.findings)
+	s.findings = append(s.findings, f)
+}
+
+// Result 返回目前为止合并后的问题、检查过的对象和 Pod 数量
+func (s *PodScanner) Result() ScanResult {
+	return ScanResult{Findings: s.findings, Objects: s.objects, Pods: s.scanned, Checks: s.checks}
+}
+
+// ScanPods 对集群中的 Pod 执行检查，把结果归属到顶层控制器，并合并同一工作负载下重复的问题
+func ScanPods(client kubernetes.Interface, pods *corev1.PodList, checks []Check) []Finding {
+	scanner := NewPodScanner(client, checks)
+	scanner.Scan(pods.Items)
+	return scanner.Result().Findings
+}
+
+// ScanCluster 分页获取扫描范围内的 Pod 并逐页检查
+func ScanCluster(ctx context.Context, client kubernetes.Interface, scope *Scope, checks []Check) (ScanResult, error) {
+	scanner := NewPodScanner(client, checks)
+	err := ForEachPodPage(ctx, client, scope, func(pods []corev1.Pod) error {
+		scanner.Scan(pods)
+		return nil
+	})
+	if err != nil {
+		return ScanResult{}, err
+	}
+	return scanner.Result(), nil
+}
+
+// CollapseFindings 合并同一工作负载下内容相同的问题，Replicas 记录出现该问题的 Pod 数量。
+// 没有控制器的 Pod 不会被合并
+func CollapseFindings(findings []Finding) []Finding {
+	s := &PodScanner{index: make(map[string]int)}
+	for _, f := range findings {
+		s.add(f)
+	}
+	return s.findings
+}
+
+// contentKey 返回不包含 Pod 名称的问题内容，用于判断两个 Pod 上的问题是否相同
+func (f Finding) contentKey() string {
+	return strings.Join([]string{
+		f.Check, f.Namespace, f.Container, f.ContainerType,
+		strings.Join(f.Capabilities, ","), fmt.Sprint(f.Hostport), f.Volume, f.Path, f.Sysctl, f.Value,
+	}, "|")
+}
@@ -0,0 +1,238 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Pod Security Admission 的命名空间标签
+const (
+	PSAEnforceLabel = "pod-security.kubernetes.io/enforce"
+	PSAAuditLabel   = "pod-security.kubernetes.io/audit"
+	PSAWarnLabel    = "pod-security.kubernetes.io/warn"
+
+	PSAEnforceVersionLabel = "pod-security.kubernetes.io/enforce-version"
+)
+
+// psaModes 是 Pod Security Admission 的三种模式和对应的标签
+var psaModes = []struct{ mode, label string }{
+	{"enforce", PSAEnforceLabel},
+	{"audit", PSAAuditLabel},
+	{"warn", PSAWarnLabel},
+}
+
+// 命名空间标签与现有 Pod 的比较结果
+const (
+	PSAStatusMatch    = "match"    // 标签就是现有 Pod 能满足的最严格级别
+	PSAStatusLooser   = "looser"   // 标签比现有 Pod 需要的宽松，可以收紧
+	PSAStatusViolated = "violated" // 现有 Pod 不满足标签的级别
+)
+
+// PSAMode 是命名空间一种 Pod Security Admission 模式的标签和评估结果
+type PSAMode struct {
+	Mode    string `json:"mode"`
+	Label   string `json:"label,omitempty"`   // 标签的原始值，未设置时为空
+	Level   Level  `json:"level"`             // 生效的级别：未设置时为 privileged，无效值按 restricted 处理
+	Invalid bool   `json:"invalid,omitempty"` // 标签值不是有效的级别
+	Status  string `json:"status"`
+}
+
+// PSAViolation 是命名空间中违反某条检查的对象
+type PSAViolation struct {
+	Check   string   `json:"check_id"`
+	Title   string   `json:"title"`
+	Level   Level    `json:"level"`
+	Objects []string `json:"objects"`
+}
+
+// NamespacePSA 是一个命名空间的 Pod Security Admission 评估结果
+type NamespacePSA struct {
+	Cluster    string         `json:"cluster,omitempty"`
+	Namespace  string         `json:"namespace"`
+	Pods       int            `json:"pods"`
+	Satisfies  Level          `json:"satisfies"` // 命名空间中所有 Pod 都满足的最严格级别
+	Modes      []PSAMode      `json:"modes"`
+	Violations []PSAViolation `json:"violations,omitempty"`
+}
+
+// Blocking 返回阻止命名空间使用 target 级别的违规：baseline 检查的违规同时阻止 baseline 和 restricted
+func (n NamespacePSA) Blocking(target Level) []PSAViolation {
+	var blocking []PSAViolation
+	for _, v := range n.Violations {
+		if target != LevelPrivileged && v.Level.Rank() <= target.Rank() {
+			blocking = append(blocking, v)
+		}
+	}
+	return blocking
+}
+
+// EvaluatePSA 根据扫描结果计算每个命名空间中所有 Pod 都满足的最严格级别，并与命名空间的
+// enforce、audit 和 warn 标签比较。通过注解豁免的问题同样计入，因为 Pod Security Admission 不识别这些注解
+func EvaluatePSA(namespaces []corev1.Namespace, result ScanResult) []NamespacePSA {
+	pods := make(map[string]int)
+	for _, o := range result.Objects {
+		pods[o.Namespace] += o.Pods
+	}
+	violations := make(map[string][]Finding)
+	for _, f := range append(append([]Finding(nil), result.Findings...), result.Suppressed...) {
+		violations[f.Namespace] = append(violations[f.Namespace], f)
+	}
+
+	evaluated := make([]NamespacePSA, 0, len(namespaces))
+	for _, ns := range namespaces {
+		n := NamespacePSA{Namespace: ns.Name, Pods: pods[ns.Name], Satisfies: LevelRestricted}
+		n.Violations = groupViolations(violations[ns.Name])
+		for _, v := range n.Violations {
+			if below := levelBelow(v.Level); below.Rank() < n.Satisfies.Rank() {
+				n.Satisfies = below
+			}
+		}
+		for _, m := range psaModes {
+			mode := PSAMode{Mode: m.mode, Label: ns.Labels[m.label], Level: LevelPrivileged}
+			if mode.Label != "" {
+				mode.Level = Level(mode.Label)
+				if mode.Level.Rank() < 0 {
+					mode.Level, mode.Invalid = LevelRestricted, true
+				}
+			}
+			switch {
+			case mode.Level.Rank() > n.Satisfies.Rank():
+				mode.Status = PSAStatusViolated
+			case mode.Level.Rank() < n.Satisfies.Rank():
+				mode.Status = PSAStatusLooser
+			default:
+				mode.Status = PSAStatusMatch
+			}
+			n.Modes = append(n.Modes, mode)
+		}
+		evaluated = append(evaluated, n)
+	}
+	return evaluated
+}
+
+// levelBelow 返回比 level 宽松一级的级别
+func levelBelow(level Level) Level {
+	if level == LevelRestricted {
+		return LevelBaseline
+	}
+	return LevelPrivileged
+}
+
+// levelAbove 返回比 level 严格一级的级别
+func levelAbove(level Level) Level {
+	if level == LevelPrivileged {
+		return LevelBaseline
+	}
+	return LevelRestricted
+}
+
+// groupViolations 按检查的注册顺序合并违规，同一对象只列出一次
+func groupViolations(findings []Finding) []PSAViolation {
+	var grouped []PSAViolation
+	for _, c := range Checks() {
+		v := PSAViolation{Check: c.ID(), Title: c.Title(), Level: c.Level()}
+		seen := make(map[string]bool)
+		for _, f := range findings {
+			if f.Check != c.ID() {
+				continue
+			}
+			o := FindingObject(f)
+			name := o.Kind + "/" + o.Name
+			if !seen[name] {
+				seen[name] = true
+				v.Objects = append(v.Objects, name)
+			}
+		}
+		if len(v.Objects) > 0 {
+			grouped = append(grouped, v)
+		}
+	}
+	return grouped
+}
+
+// WritePSAReport 以文本形式写出每个命名空间的评估结果：先是汇总表，再列出可以收紧的标签、
+// 被现有 Pod 违反的标签，以及收紧到下一级时会违反的检查
+func WritePSAReport(w io.Writer, namespaces []NamespacePSA) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tPODS\tSATISFIES\tENFORCE\tAUDIT\tWARN")
+	for _, n := range namespaces {
+		row := []string{n.displayName(), fmt.Sprint(n.Pods), string(n.Satisfies)}
+		for _, m := range n.Modes {
+			row = append(row, m.cell())
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "(- unset, ↑ can be tightened, ✗ violated by existing pods)")
+
+	for _, n := range namespaces {
+		var lines []string
+		violated := make(map[Level]bool)
+		for _, m := range n.Modes {
+			label := m.Label
+			if label == "" {
+				label = "unset"
+			}
+			switch m.Status {
+			case PSAStatusLooser:
+				lines = append(lines, fmt.Sprintf("%s is %s, can be tightened to %s", m.Mode, label, n.Satisfies))
+			case PSAStatusViolated:
+				if m.Invalid {
+					label += " (invalid, treated as restricted)"
+				}
+				lines = append(lines, fmt.Sprintf("%s is %s, but existing pods only satisfy %s", m.Mode, label, n.Satisfies))
+				if !violated[m.Level] {
+					violated[m.Level] = true
+					lines = append(lines, violationLines(n.Blocking(m.Level))...)
+				}
+			}
+		}
+		if next := levelAbove(n.Satisfies); n.Satisfies != LevelRestricted && !violated[next] {
+			lines = append(lines, fmt.Sprintf("tightening to %s would reject:", next))
+			lines = append(lines, violationLines(n.Blocking(next))...)
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\nnamespace %s\n", n.displayName())
+		for _, line := range lines {
+			fmt.Fprintf(w, "  %s\n", line)
+		}
+	}
+	return nil
+}
+
+func violationLines(violations []PSAViolation) []string {
+	lines := make([]string, 0, len(violations))
+	for _, v := range violations {
+		lines = append(lines, fmt.Sprintf("  %s (%s): %s", v.Title, v.Level, strings.Join(v.Objects, ", ")))
+	}
+	return lines
+}
+
+func (n NamespacePSA) displayName() string {
+	if n.Cluster != "" {
+		return n.Cluster + "/" + n.Namespace
+	}
+	return n.Namespace
+}
+
+func (m PSAMode) cell() string {
+	cell := m.Label
+	if cell == "" {
+		cell = "-"
+	}
+	switch m.Status {
+	case PSAStatusLooser:
+		cell += " ↑"
+	case PSAStatusViolated:
+		cell += " ✗"
+	}
+	return cell
+}
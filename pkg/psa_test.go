@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// psaNamespace 返回带有 enforce、audit 和 warn 标签的命名空间，空值表示不设置该标签
+func psaNamespace(name, enforce, audit, warn string) corev1.Namespace {
+	ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{}}}
+	for label, value := range map[string]string{PSAEnforceLabel: enforce, PSAAuditLabel: audit, PSAWarnLabel: warn} {
+		if value != "" {
+			ns.Labels[label] = value
+		}
+	}
+	return ns
+}
+
+func TestEvaluatePSA(t *testing.T) {
+	// privileged 是 Baseline 检查，runasnonroot 是 Restricted 检查
+	result := ScanResult{
+		Objects: []ScannedObject{
+			{Namespace: "clean", Kind: "Deployment", Name: "web", Pods: 3},
+			{Namespace: "nonroot", Kind: "Deployment", Name: "api", Pods: 2},
+			{Namespace: "nonroot", Kind: "Pod", Name: "debug", Pods: 1},
+			{Namespace: "privileged", Kind: "DaemonSet", Name: "csi", Pods: 4},
+		},
+		Findings: []Finding{
+			{Check: "runasnonroot", Level: LevelRestricted, Namespace: "nonroot", Pod: "api-7d9c5b6f4b-x2k8p", OwnerKind: "Deployment", OwnerName: "api"},
+			{Check: "runasnonroot", Level: LevelRestricted, Namespace: "nonroot", Pod: "api-7d9c5b6f4b-q8m2z", OwnerKind: "Deployment", OwnerName: "api"},
+			{Check: "runasnonroot", Level: LevelRestricted, Namespace: "nonroot", Pod: "debug"},
+			{Check: "runasnonroot", Level: LevelRestricted, Namespace: "privileged", Pod: "csi-4xz2q", OwnerKind: "DaemonSet", OwnerName: "csi"},
+		},
+		// 通过注解豁免的问题同样计入，Pod Security Admission 不识别这些注解
+		Suppressed: []Finding{
+			{Check: "privileged", Level: LevelBaseline, Namespace: "privileged", Pod: "csi-4xz2q", OwnerKind: "DaemonSet", OwnerName: "csi",
+				Suppression: &Suppression{Check: "privileged", Source: SuppressionSourceAnnotation}},
+		},
+	}
+
+	type mode struct {
+		label   string
+		level   Level
+		invalid bool
+		status  string
+	}
+	tests := []struct {
+		name      string
+		namespace corev1.Namespace
+		pods      int
+		satisfies Level
+		modes     []mode // enforce、audit、warn
+		blocking  map[Level][]string
+	}{
+		{
+			name:      "no labels on a clean namespace",
+			namespace: psaNamespace("clean", "", "", ""),
+			pods:      3,
+			satisfies: LevelRestricted,
+			modes: []mode{
+				{level: LevelPrivileged, status: PSAStatusLooser},
+				{level: LevelPrivileged, status: PSAStatusLooser},
+				{level: LevelPrivileged, status: PSAStatusLooser},
+			},
+		},
+		{
+			name:      "labels match",
+			namespace: psaNamespace("clean", "restricted", "restricted", "restricted"),
+			pods:      3,
+			satisfies: LevelRestricted,
+			modes: []mode{
+				{label: "restricted", level: LevelRestricted, status: PSAStatusMatch},
+				{label: "restricted", level: LevelRestricted, status: PSAStatusMatch},
+				{label: "restricted", level: LevelRestricted, status: PSAStatusMatch},
+			},
+		},
+		{
+			name:      "mixed levels",
+			namespace: psaNamespace("nonroot", "privileged", "baseline", "restricted"),
+			pods:      3,
+			satisfies: LevelBaseline,
+			modes: []mode{
+				{label: "privileged", level: LevelPrivileged, status: PSAStatusLooser},
+				{label: "baseline", level: LevelBaseline, status: PSAStatusMatch},
+				{label: "restricted", level: LevelRestricted, status: PSAStatusViolated},
+			},
+			blocking: map[Level][]string{
+				LevelBaseline:   nil,
+				LevelRestricted: {"runasnonroot: Deployment/api, Pod/debug"},
+			},
+		},
+		{
+			name:      "exempted findings still count",
+			namespace: psaNamespace("privileged", "baseline", "", "baseline"),
+			pods:      4,
+			satisfies: LevelPrivileged,
+			modes: []mode{
+				{label: "baseline", level: LevelBaseline, status: PSAStatusViolated},
+				{level: LevelPrivileged, status: PSAStatusMatch},
+				{label: "baseline", level: LevelBaseline, status: PSAStatusViolated},
+			},
+			blocking: map[Level][]string{
+				LevelPrivileged: nil,
+				LevelBaseline:   {"privileged: DaemonSet/csi"},
+				LevelRestricted: {"privileged: DaemonSet/csi", "runasnonroot: DaemonSet/csi"},
+			},
+		},
+		{
+			name:      "invalid label treated as restricted",
+			namespace: psaNamespace("nonroot", "Restricted", "", ""),
+			pods:      3,
+			satisfies: LevelBaseline,
+			modes: []mode{
+				{label: "Restricted", level: LevelRestricted, invalid: true, status: PSAStatusViolated},
+				{level: LevelPrivileged, status: PSAStatusLooser},
+				{level: LevelPrivileged, status: PSAStatusLooser},
+			},
+		},
+		{
+			name:      "namespace without pods",
+			namespace: psaNamespace("empty", "baseline", "", ""),
+			satisfies: LevelRestricted,
+			modes: []mode{
+				{label: "baseline", level: LevelBaseline, status: PSAStatusLooser},
+				{level: LevelPrivileged, status: PSAStatusLooser},
+				{level: LevelPrivileged, status: PSAStatusLooser},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evaluated := EvaluatePSA([]corev1.Namespace{tt.namespace}, result)
+			if len(evaluated) != 1 {
+				t.Fatalf("got %d namespaces, want 1", len(evaluated))
+			}
+			n := evaluated[0]
+			if n.Namespace != tt.namespace.Name || n.Pods != tt.pods || n.Satisfies != tt.satisfies {
+				t.Errorf("got %s with %d pods satisfying %s, want %s with %d pods satisfying %s",
+					n.Namespace, n.Pods, n.Satisfies, tt.namespace.Name, tt.pods, tt.satisfies)
+			}
+			var modes []mode
+			for i, m := range n.Modes {
+				if want := psaModes[i].mode; m.Mode != want {
+					t.Errorf("mode %d = %s, want %s", i, m.Mode, want)
+				}
+				modes = append(modes, mode{m.Label, m.Level, m.Invalid, m.Status})
+			}
+			if !reflect.DeepEqual(modes, tt.modes) {
+				t.Errorf("modes = %+v, want %+v", modes, tt.modes)
+			}
+			for level, want := range tt.blocking {
+				var got []string
+				for _, v := range n.Blocking(level) {
+					got = append(got, v.Check+": "+strings.Join(v.Objects, ", "))
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("Blocking(%s) = %v, want %v", level, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluatePSAOrder(t *testing.T) {
+	namespaces := []corev1.Namespace{psaNamespace("b", "", "", ""), psaNamespace("a", "", "", "")}
+	var got []string
+	for _, n := range EvaluatePSA(namespaces, ScanResult{}) {
+		got = append(got, n.Namespace)
+	}
+	if want := []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("namespaces = %v, want the input order %v", got, want)
+	}
+}
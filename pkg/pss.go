@@ -1,400 +1,359 @@
 package pkg
 
 import (
-	"github.com/spf13/pflag"
 	"strings"
+
+	corev1 "k8s.io/api/core/v1"
 )
 
 type Finding struct {
-	Check        string   //表示进行安全检查的标识或名称
-	Namespace    string   //表示容器所在的命名空间
-	Pod          string   //表示容器所属的 Pod 名称
-	Container    string   `json:",omitempty"` //表示容器的名称
-	Capabilities []string `json:",omitempty"` //表示容器的 Linux 容器权限（capabilities）列表
-	Hostport     int      `json:",omitempty"` //表示容器使用的主机端口
-	Volume       string   `json:",omitempty"` //表示容器挂载的卷
-	Path         string   `json:",omitempty"` //表示容器中的路径
-	Sysctl       string   `json:",omitempty"` //表示容器的 sysctl 设置
-	Image        string   `json:",omitempty"` //表示容器所使用的镜像
+	Check         string          //表示进行安全检查的标识或名称
+	Severity      Severity        //表示问题的严重程度
+	Level         Level           //表示检查项所属的 PSS 级别
+	Cluster       string          `json:",omitempty"` //表示所属集群(kubeconfig 上下文名称)
+	Namespace     string          //表示容器所在的命名空间
+	Pod           string          //表示容器所属的 Pod 名称
+	Container     string          `json:",omitempty"` //表示容器的名称
+	ContainerType string          `json:",omitempty"` //表示容器的类型(container/initContainer/ephemeralContainer)
+	Capabilities  []string        `json:",omitempty"` //表示容器的 Linux 容器权限（capabilities）列表
+	Hostport      int             `json:",omitempty"` //表示容器使用的主机端口
+	Volume        string          `json:",omitempty"` //表示容器挂载的卷
+	Path          string          `json:",omitempty"` //表示容器中的路径
+	Sysctl        string          `json:",omitempty"` //表示容器的 sysctl 设置
+	Value         string          `json:",omitempty"` //表示违规的配置值，例如卷类型、runAsUser 或 seccomp profile
+	Image         string          `json:",omitempty"` //表示容器所使用的镜像
+	Kind          string          `json:",omitempty"` //表示被检查对象的类型，离线扫描清单时为工作负载类型
+	Source        *ManifestSource `json:",omitempty"` //表示对象在清单文件中的位置，仅离线扫描时存在
+	OwnerKind     string          `json:",omitempty"` //表示 Pod 所属顶层控制器的类型，例如 Deployment
+	OwnerName     string          `json:",omitempty"` //表示 Pod 所属顶层控制器的名称
+	Replicas      int             `json:",omitempty"` //表示该工作负载中存在此问题的副本数
+	Suppression   *Suppression    `json:",omitempty"` //表示匹配到的抑制规则，规则过期时问题仍然有效
+
+	podAlsoInvalid bool //容器覆盖的字段在 Pod 级别同样违规，只删除容器的设置后容器会继承 Pod 的非法值
 }
 
-func Hostpid(options *pflag.FlagSet) []Finding {
-	var hostPidCont []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		pod.GetObjectMeta()
-		if pod.Spec.HostPID {
-			p := Finding{Check: "hostpid", Namespace: pod.Namespace, Pod: pod.Name, Container: ""}
-			hostPidCont = append(hostPidCont, p)
-		}
+const (
+	ContainerTypeContainer = "container"
+	ContainerTypeInit      = "initContainer"
+	ContainerTypeEphemeral = "ephemeralContainer"
+)
+
+// visitContainers 依次访问普通容器、初始化容器和临时容器
+func visitContainers(spec *corev1.PodSpec, visit func(container *corev1.Container, containerType string)) {
+	for i := range spec.Containers {
+		visit(&spec.Containers[i], ContainerTypeContainer)
+	}
+	for i := range spec.InitContainers {
+		visit(&spec.InitContainers[i], ContainerTypeInit)
+	}
+	for i := range spec.EphemeralContainers {
+		// EphemeralContainerCommon 与 Container 字段完全一致，可以直接转换
+		visit((*corev1.Container)(&spec.EphemeralContainers[i].EphemeralContainerCommon), ContainerTypeEphemeral)
 	}
-	return hostPidCont
 }
 
-func Hostnet(options *pflag.FlagSet) []Finding {
-	var hostNetCont []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
+func podFinding(pod *corev1.Pod) Finding {
+	return Finding{Namespace: pod.Namespace, Pod: pod.Name}
+}
 
-		if pod.Spec.HostNetwork {
-			p := Finding{Check: "hostnet", Namespace: pod.Namespace, Pod: pod.Name}
-			hostNetCont = append(hostNetCont, p)
-		}
+func containerFinding(pod *corev1.Pod, container *corev1.Container, containerType string) Finding {
+	return Finding{Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name, ContainerType: containerType, Image: container.Image}
+}
+
+func init() {
+	Register(NewCheck(CheckInfo{
+		ID:          "hostpid",
+		Title:       "Host PID",
+		Level:       LevelBaseline,
+		Severity:    SeverityHigh,
+		Description: "Pod 共享主机 PID 命名空间，可以看到并向主机上的所有进程发送信号",
+		Remediation: "删除 spec.hostPID 或将其设置为 false",
+	}, Hostpid))
+	Register(NewCheck(CheckInfo{
+		ID:          "hostnet",
+		Title:       "Host Network",
+		Level:       LevelBaseline,
+		Severity:    SeverityHigh,
+		Description: "Pod 使用主机网络命名空间，可以监听主机端口并绕过网络策略",
+		Remediation: "删除 spec.hostNetwork 或将其设置为 false",
+	}, Hostnet))
+	Register(NewCheck(CheckInfo{
+		ID:          "hostipc",
+		Title:       "Host IPC",
+		Level:       LevelBaseline,
+		Severity:    SeverityHigh,
+		Description: "Pod 共享主机 IPC 命名空间，可以访问主机上其他进程的共享内存",
+		Remediation: "删除 spec.hostIPC 或将其设置为 false",
+	}, Hostipc))
+	Register(NewCheck(CheckInfo{
+		ID:          "hostports",
+		Title:       "Host Ports",
+		Level:       LevelBaseline,
+		Severity:    SeverityMedium,
+		Description: "容器占用了节点上的主机端口，会绕过 Service 暴露服务并限制调度",
+		Remediation: "删除 ports[*].hostPort，改用 Service 暴露端口",
+	}, HostPorts))
+	Register(NewCheck(CheckInfo{
+		ID:          "hostpath",
+		Title:       "Host Path",
+		Level:       LevelBaseline,
+		Severity:    SeverityHigh,
+		Description: "Pod 挂载了 hostPath 卷，可以读写节点文件系统",
+		Remediation: "用 emptyDir、ConfigMap、PVC 等卷类型替换 hostPath 卷",
+	}, HostPath))
+	Register(NewCheck(CheckInfo{
+		ID:          "hostprocess",
+		Title:       "Host Process",
+		Level:       LevelBaseline,
+		Severity:    SeverityCritical,
+		Description: "Windows HostProcess 容器直接以主机进程的身份运行",
+		Remediation: "删除 securityContext.windowsOptions.hostProcess 或将其设置为 false",
+	}, HostProcess))
+	Register(NewCheck(CheckInfo{
+		ID:          "privileged",
+		Title:       "Privileged Container",
+		Level:       LevelBaseline,
+		Severity:    SeverityCritical,
+		Description: "特权容器拥有主机上几乎所有的权限，等同于节点 root",
+		Remediation: "删除 securityContext.privileged 或将其设置为 false",
+	}, Privileged))
+	Register(NewVersionedCheck(CheckInfo{
+		ID:          "allowprivesc",
+		Title:       "Allow Privilege Escalation",
+		Level:       LevelRestricted,
+		Severity:    SeverityMedium,
+		Description: "容器未禁止权限提升，进程可以通过 setuid 等方式获得比父进程更多的权限",
+		Remediation: "将 securityContext.allowPrivilegeEscalation 设置为 false",
+	}, AllowPrivEsc))
+	Register(NewCheck(CheckInfo{
+		ID:          "addedcaps",
+		Title:       "Added Capabilities",
+		Level:       LevelBaseline,
+		Severity:    SeverityMedium,
+		Description: "容器添加了 Baseline 允许的默认集合之外的 Linux capabilities，SYS_ADMIN 等危险的 capabilities 严重程度更高",
+		Remediation: "从 securityContext.capabilities.add 中移除 " + strings.Join(baselineCapabilities, "、") + " 之外的 capabilities",
+	}, AddedCapabilities))
+	Register(NewCheck(CheckInfo{
+		ID:          "seccomp",
+		Title:       "Seccomp Disabled",
+		Level:       LevelBaseline,
+		Severity:    SeverityMedium,
+		Description: "容器生效的 seccomp profile 被显式设置为 Unconfined 或无效的值，可以调用全部系统调用",
+		Remediation: "将 securityContext.seccompProfile.type 设置为 RuntimeDefault，并删除已废弃的 seccomp.security.alpha.kubernetes.io 注解",
+	}, Seccomp))
+	Register(NewVersionedCheck(CheckInfo{
+		ID:          "apparmor",
+		Title:       "Apparmor Disabled",
+		Level:       LevelBaseline,
+		Severity:    SeverityMedium,
+		Description: "容器的 AppArmor profile 被设置为 Unconfined 或其他不允许的值，只允许 RuntimeDefault 和 Localhost",
+		Remediation: "删除 securityContext.appArmorProfile 中的 Unconfined 以及值为 unconfined 的 container.apparmor.security.beta.kubernetes.io 注解",
+	}, Apparmor))
+	Register(NewVersionedCheck(CheckInfo{
+		ID:          "selinux",
+		Title:       "Custom SELinux Options",
+		Level:       LevelBaseline,
+		Severity:    SeverityHigh,
+		Description: "Pod 或容器设置了自定义的 SELinux user、role，或 container_t、container_init_t、container_kvm_t 之外的 type(1.31 起还允许 container_engine_t)",
+		Remediation: "删除 seLinuxOptions 中的 user 和 role，type 只使用 container_t、container_init_t 或 container_kvm_t",
+	}, SELinux))
+	Register(NewCheck(CheckInfo{
+		ID:          "procmount",
+		Title:       "Unmasked Procmount",
+		Level:       LevelBaseline,
+		Severity:    SeverityHigh,
+		Description: "容器使用 Unmasked proc 挂载，/proc 中的敏感路径没有被屏蔽",
+		Remediation: "删除 securityContext.procMount 或将其设置为 Default",
+	}, Procmount))
+	Register(NewVersionedCheck(CheckInfo{
+		ID:          "sysctl",
+		Title:       "Unsafe Sysctl",
+		Level:       LevelBaseline,
+		Severity:    SeverityMedium,
+		Description: "Pod 配置了不在安全列表中的 sysctl，可能影响节点上的其他工作负载",
+		Remediation: "从 securityContext.sysctls 中移除不安全的 sysctl",
+	}, Sysctl))
+}
+
+func Hostpid(pod *corev1.Pod) []Finding {
+	if pod.Spec.HostPID {
+		return []Finding{podFinding(pod)}
 	}
-	return hostNetCont
+	return nil
 }
 
-func Hostipc(options *pflag.FlagSet) []Finding {
-	var hostIpcCont []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		if pod.Spec.HostIPC {
-			p := Finding{Check: "hostipc", Namespace: pod.Namespace, Pod: pod.Name, Container: ""}
-			hostIpcCont = append(hostIpcCont, p)
-		}
+func Hostnet(pod *corev1.Pod) []Finding {
+	if pod.Spec.HostNetwork {
+		return []Finding{podFinding(pod)}
+	}
+	return nil
+}
+
+func Hostipc(pod *corev1.Pod) []Finding {
+	if pod.Spec.HostIPC {
+		return []Finding{podFinding(pod)}
 	}
-	return hostIpcCont
+	return nil
 }
 
-func HostPorts(options *pflag.FlagSet) []Finding {
+func HostPorts(pod *corev1.Pod) []Finding {
 	var hostPorts []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		for _, container := range pod.Spec.Containers {
-			// 容器是否指定了端口
-			cports := container.Ports != nil
-			if cports {
-				for _, port := range container.Ports {
-					// 该端口是主机端口?
-					if port.HostPort != 0 {
-						p := Finding{Check: "Host Ports", Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name, Hostport: int(port.HostPort)}
-						hostPorts = append(hostPorts, p)
-					}
-				}
-			}
-		}
-		for _, init_container := range pod.Spec.InitContainers {
-			cports := init_container.Ports != nil
-			if cports {
-				for _, port := range init_container.Ports {
-					if port.HostPort != 0 {
-						p := Finding{Check: "Host Ports", Namespace: pod.Namespace, Pod: pod.Name, Container: init_container.Name, Hostport: int(port.HostPort)}
-						hostPorts = append(hostPorts, p)
-					}
-				}
-			}
-		}
-		for _, eph_container := range pod.Spec.EphemeralContainers {
-			cports := eph_container.Ports != nil
-			if cports {
-				for _, port := range eph_container.Ports {
-					if port.HostPort != 0 {
-						p := Finding{Check: "Host Ports", Namespace: pod.Namespace, Pod: pod.Name, Container: eph_container.Name, Hostport: int(port.HostPort)}
-						hostPorts = append(hostPorts, p)
-					}
-				}
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		for _, port := range container.Ports {
+			// 该端口是主机端口?
+			if port.HostPort != 0 {
+				p := containerFinding(pod, container, containerType)
+				p.Hostport = int(port.HostPort)
+				hostPorts = append(hostPorts, p)
 			}
 		}
-	}
+	})
 	return hostPorts
 }
 
-func HostPath(options *pflag.FlagSet) []Finding {
+func HostPath(pod *corev1.Pod) []Finding {
 	var hostPath []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		host_path := pod.Spec.Volumes != nil
-		if host_path {
-			for _, vol := range pod.Spec.Volumes {
-				if vol.HostPath != nil {
-					p := Finding{Check: "Host Path", Namespace: pod.Namespace, Pod: pod.Name, Volume: vol.Name, Path: vol.HostPath.Path}
-					hostPath = append(hostPath, p)
-				}
-			}
+	for _, vol := range pod.Spec.Volumes {
+		if vol.HostPath != nil {
+			p := podFinding(pod)
+			p.Volume = vol.Name
+			p.Path = vol.HostPath.Path
+			hostPath = append(hostPath, p)
 		}
 	}
 	return hostPath
 }
 
-func HostProcess(options *pflag.FlagSet) []Finding {
+func HostProcess(pod *corev1.Pod) []Finding {
 	var hostprocesscont []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		hostProcessPod := pod.Spec.SecurityContext.WindowsOptions != nil && *pod.Spec.SecurityContext.WindowsOptions.HostProcess
-		if hostProcessPod {
-			p := Finding{Check: "HostProcess", Namespace: pod.Namespace, Pod: pod.Name}
-			hostprocesscont = append(hostprocesscont, p)
-		}
-		for _, container := range pod.Spec.Containers {
-			hostProcessCont := container.SecurityContext != nil && container.SecurityContext.WindowsOptions != nil && *container.SecurityContext.WindowsOptions.HostProcess
-			if hostProcessCont {
-				p := Finding{Check: "HostProcess", Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name}
-				hostprocesscont = append(hostprocesscont, p)
-			}
-		}
-		for _, init_container := range pod.Spec.InitContainers {
-			hostProcessCont := init_container.SecurityContext != nil && init_container.SecurityContext.WindowsOptions != nil && *init_container.SecurityContext.WindowsOptions.HostProcess
-			if hostProcessCont {
-				p := Finding{Check: "HostProcess", Namespace: pod.Namespace, Pod: pod.Name, Container: init_container.Name}
-				hostprocesscont = append(hostprocesscont, p)
-			}
-		}
-		for _, eph_container := range pod.Spec.EphemeralContainers {
-			hostProcessCont := eph_container.SecurityContext != nil && eph_container.SecurityContext.WindowsOptions != nil && *eph_container.SecurityContext.WindowsOptions.HostProcess
-			if hostProcessCont {
-				p := Finding{Check: "HostProcess", Namespace: pod.Namespace, Pod: pod.Name, Container: eph_container.Name}
-				hostprocesscont = append(hostprocesscont, p)
-			}
-		}
+	psc := pod.Spec.SecurityContext
+	if psc != nil && psc.WindowsOptions != nil && psc.WindowsOptions.HostProcess != nil && *psc.WindowsOptions.HostProcess {
+		hostprocesscont = append(hostprocesscont, podFinding(pod))
 	}
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		sc := container.SecurityContext
+		if sc != nil && sc.WindowsOptions != nil && sc.WindowsOptions.HostProcess != nil && *sc.WindowsOptions.HostProcess {
+			hostprocesscont = append(hostprocesscont, containerFinding(pod, container, containerType))
+		}
+	})
 	return hostprocesscont
 }
 
-func Privileged(options *pflag.FlagSet) []Finding {
+func Privileged(pod *corev1.Pod) []Finding {
 	var privCont []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		for _, container := range pod.Spec.Containers {
-			privileged_container := container.SecurityContext != nil && container.SecurityContext.Privileged != nil && *container.SecurityContext.Privileged
-			if privileged_container {
-				p := Finding{Check: "privileged", Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name}
-				privCont = append(privCont, p)
-			}
-		}
-		for _, init_container := range pod.Spec.InitContainers {
-			privileged_container := init_container.SecurityContext != nil && init_container.SecurityContext.Privileged != nil && *init_container.SecurityContext.Privileged
-			if privileged_container {
-				p := Finding{Check: "privileged", Namespace: pod.Namespace, Pod: pod.Name, Container: init_container.Name}
-				privCont = append(privCont, p)
-			}
-		}
-		for _, eph_container := range pod.Spec.EphemeralContainers {
-			privileged_container := eph_container.SecurityContext != nil && eph_container.SecurityContext.Privileged != nil && *eph_container.SecurityContext.Privileged
-			if privileged_container {
-				p := Finding{Check: "privileged", Namespace: pod.Namespace, Pod: pod.Name, Container: eph_container.Name}
-				privCont = append(privCont, p)
-			}
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		sc := container.SecurityContext
+		if sc != nil && sc.Privileged != nil && *sc.Privileged {
+			privCont = append(privCont, containerFinding(pod, container, containerType))
 		}
-	}
+	})
 	return privCont
 }
 
-func AllowPrivEsc(options *pflag.FlagSet) []Finding {
+func AllowPrivEsc(pod *corev1.Pod, version PSSVersion) []Finding {
+	if windowsExempt(pod, version) {
+		return nil
+	}
 	var allowPrivEscCont []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		for _, container := range pod.Spec.Containers {
-			// 如果没有安全上下文，或者有安全上下文并且没有提到允许权限提升，则默认情况为true
-			allowPrivilegeEscalation := (container.SecurityContext == nil) || (container.SecurityContext != nil && container.SecurityContext.AllowPrivilegeEscalation == nil)
-			if allowPrivilegeEscalation {
-				p := Finding{Check: "allowprivesc", Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name}
-				allowPrivEscCont = append(allowPrivEscCont, p)
-			}
-		}
-		for _, init_container := range pod.Spec.InitContainers {
-			allowPrivilegeEscalation := (init_container.SecurityContext == nil) || (init_container.SecurityContext != nil && init_container.SecurityContext.AllowPrivilegeEscalation == nil)
-			if allowPrivilegeEscalation {
-				p := Finding{Check: "allowprivesc", Namespace: pod.Namespace, Pod: pod.Name, Container: init_container.Name}
-				allowPrivEscCont = append(allowPrivEscCont, p)
-			}
-		}
-		for _, eph_container := range pod.Spec.EphemeralContainers {
-			allowPrivilegeEscalation := (eph_container.SecurityContext == nil) || (eph_container.SecurityContext != nil && eph_container.SecurityContext.AllowPrivilegeEscalation == nil)
-			if allowPrivilegeEscalation {
-				p := Finding{Check: "allowprivesc", Namespace: pod.Namespace, Pod: pod.Name, Container: eph_container.Name}
-				allowPrivEscCont = append(allowPrivEscCont, p)
-			}
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		// 没有显式设置为 false 时默认允许权限提升
+		sc := container.SecurityContext
+		if sc == nil || sc.AllowPrivilegeEscalation == nil || *sc.AllowPrivilegeEscalation {
+			allowPrivEscCont = append(allowPrivEscCont, containerFinding(pod, container, containerType))
 		}
-	}
+	})
 	return allowPrivEscCont
 }
 
-func AddedCapabilities(options *pflag.FlagSet) []Finding {
-	var capAdded []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		for _, container := range pod.Spec.Containers {
-			cap_added := container.SecurityContext != nil && container.SecurityContext.Capabilities != nil && container.SecurityContext.Capabilities.Add != nil
-			if cap_added {
-				var added_caps []string
-				for _, cap := range container.SecurityContext.Capabilities.Add {
-					added_caps = append(added_caps, string(cap))
-				}
-				p := Finding{Check: "Added Capabilities", Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name, Capabilities: added_caps}
-				capAdded = append(capAdded, p)
-			}
-		}
-
-		for _, init_container := range pod.Spec.InitContainers {
-			cap_added := init_container.SecurityContext != nil && init_container.SecurityContext.Capabilities != nil && init_container.SecurityContext.Capabilities.Add != nil
-			if cap_added {
-				var added_caps []string
-				for _, cap := range init_container.SecurityContext.Capabilities.Add {
-					added_caps = append(added_caps, string(cap))
-				}
-				p := Finding{Check: "Added Capabilities", Namespace: pod.Namespace, Pod: pod.Name, Container: init_container.Name, Capabilities: added_caps}
-				capAdded = append(capAdded, p)
-			}
-		}
+// apparmorAnnotationPrefix 是 Kubernetes 1.30 之前为每个容器设置 AppArmor profile 的注解前缀
+const apparmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
 
-		for _, eph_container := range pod.Spec.EphemeralContainers {
-			cap_added := eph_container.SecurityContext != nil && eph_container.SecurityContext.Capabilities != nil && eph_container.SecurityContext.Capabilities.Add != nil
-			if cap_added {
-				var added_caps []string
-				for _, cap := range eph_container.SecurityContext.Capabilities.Add {
-					added_caps = append(added_caps, string(cap))
-				}
-				p := Finding{Check: "Added Capabilities", Namespace: pod.Namespace, Pod: pod.Name, Container: eph_container.Name, Capabilities: added_caps}
-				capAdded = append(capAdded, p)
-			}
-		}
+func Apparmor(pod *corev1.Pod, version PSSVersion) []Finding {
+	// 默认使用运行时的 profile，只有显式设置为 Unconfined 等不允许的值才是问题
+	var apparmor []Finding
+	if version.AtLeast(appArmorFieldSince) {
+		apparmor = appArmorFieldFindings(pod)
 	}
-	return capAdded
-}
-
-func DroppedCapabilities(options *pflag.FlagSet) []Finding {
-	var capDropped []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		for _, container := range pod.Spec.Containers {
-			cap_dropped := container.SecurityContext != nil && container.SecurityContext.Capabilities != nil && container.SecurityContext.Capabilities.Drop != nil
-			if cap_dropped {
-				var dropped_caps []string
-				for _, cap := range container.SecurityContext.Capabilities.Drop {
-					dropped_caps = append(dropped_caps, string(cap))
-				}
-				p := Finding{Check: "Dropped Capabilities", Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name, Capabilities: dropped_caps}
-				capDropped = append(capDropped, p)
-			}
-		}
 
-		for _, init_container := range pod.Spec.InitContainers {
-			cap_dropped := init_container.SecurityContext != nil && init_container.SecurityContext.Capabilities != nil && init_container.SecurityContext.Capabilities.Drop != nil
-			if cap_dropped {
-				var dropped_caps []string
-				for _, cap := range init_container.SecurityContext.Capabilities.Drop {
-					dropped_caps = append(dropped_caps, string(cap))
-				}
-				p := Finding{Check: "Dropped Capabilities", Namespace: pod.Namespace, Pod: pod.Name, Container: init_container.Name, Capabilities: dropped_caps}
-				capDropped = append(capDropped, p)
-			}
+	// 注解按容器名称设置，值只允许 runtime/default 和 localhost/<profile>
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		val, ok := pod.Annotations[apparmorAnnotationPrefix+container.Name]
+		if !ok || val == "runtime/default" || strings.HasPrefix(val, "localhost/") {
+			return
 		}
+		p := containerFinding(pod, container, containerType)
+		p.Value = val + " (annotation)"
+		apparmor = append(apparmor, p)
+	})
+	return apparmor
+}
 
-		for _, eph_container := range pod.Spec.EphemeralContainers {
-			cap_dropped := eph_container.SecurityContext != nil && eph_container.SecurityContext.Capabilities != nil && eph_container.SecurityContext.Capabilities.Drop != nil
-			if cap_dropped {
-				var dropped_caps []string
-				for _, cap := range eph_container.SecurityContext.Capabilities.Drop {
-					dropped_caps = append(dropped_caps, string(cap))
-				}
-				p := Finding{Check: "Dropped Capabilities", Namespace: pod.Namespace, Pod: pod.Name, Container: eph_container.Name, Capabilities: dropped_caps}
-				capDropped = append(capDropped, p)
-			}
-		}
+// appArmorFieldFindings 检查 Pod 和容器的 securityContext.appArmorProfile 字段
+func appArmorFieldFindings(pod *corev1.Pod) []Finding {
+	var podValue *corev1.AppArmorProfileType
+	if psc := pod.Spec.SecurityContext; psc != nil && psc.AppArmorProfile != nil {
+		podValue = &psc.AppArmorProfile.Type
 	}
-	return capDropped
+	return mergedField(pod, podValue,
+		func(sc *corev1.SecurityContext) *corev1.AppArmorProfileType {
+			if sc.AppArmorProfile == nil {
+				return nil
+			}
+			return &sc.AppArmorProfile.Type
+		},
+		func(t corev1.AppArmorProfileType) bool {
+			return t == corev1.AppArmorProfileTypeRuntimeDefault || t == corev1.AppArmorProfileTypeLocalhost
+		},
+		func(t corev1.AppArmorProfileType) string { return string(t) }, false)
 }
 
-func Seccomp(options *pflag.FlagSet) []Finding {
-	var seccomp []Finding
-	pods := ConnectWithPods(options)
-	// 如果pod是无限制的,容器也是无限制的
-	// 理论上,如果pod中的所有容器都是无限制的,我们可以在pod级别对其进行标记
-	for _, pod := range pods.Items {
-		unconfined_pod := (pod.Spec.SecurityContext == nil) || (pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.SeccompProfile == nil) || (pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.SeccompProfile != nil && pod.Spec.SecurityContext.SeccompProfile.Type == "Unconfined")
-		if unconfined_pod {
-			//log.Printf("Pod name %s was unconfined at pod level", pod.Name)
-			for _, container := range pod.Spec.Containers {
-				unconfined_container := (container.SecurityContext == nil) || (container.SecurityContext != nil && container.SecurityContext.SeccompProfile == nil) || (container.SecurityContext != nil && container.SecurityContext.SeccompProfile != nil && container.SecurityContext.SeccompProfile.Type == "Unconfined")
-				if unconfined_container {
-					p := Finding{Check: "Seccomp Disabled", Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name}
-					seccomp = append(seccomp, p)
-				}
-			}
-			for _, init_container := range pod.Spec.InitContainers {
-				unconfined_init_container := (init_container.SecurityContext == nil) || (init_container.SecurityContext != nil && init_container.SecurityContext.SeccompProfile == nil) || (init_container.SecurityContext != nil && init_container.SecurityContext.SeccompProfile != nil && init_container.SecurityContext.SeccompProfile.Type == "Unconfined")
-				if unconfined_init_container {
-					p := Finding{Check: "Seccomp Disabled", Namespace: pod.Namespace, Pod: pod.Name, Container: init_container.Name}
-					seccomp = append(seccomp, p)
-				}
-			}
-			for _, eph_container := range pod.Spec.EphemeralContainers {
-				unconfined_eph_container := (eph_container.SecurityContext == nil) || (eph_container.SecurityContext != nil && eph_container.SecurityContext.SeccompProfile == nil) || (eph_container.SecurityContext != nil && eph_container.SecurityContext.SeccompProfile != nil && eph_container.SecurityContext.SeccompProfile.Type == "Unconfined")
-				if unconfined_eph_container {
-					p := Finding{Check: "Seccomp Disabled", Namespace: pod.Namespace, Pod: pod.Name, Container: eph_container.Name}
-					seccomp = append(seccomp, p)
-				}
-			}
-		}
+func SELinux(pod *corev1.Pod, version PSSVersion) []Finding {
+	var podValue *corev1.SELinuxOptions
+	if psc := pod.Spec.SecurityContext; psc != nil {
+		podValue = psc.SELinuxOptions
 	}
-	return seccomp
+	return mergedField(pod, podValue,
+		func(sc *corev1.SecurityContext) *corev1.SELinuxOptions { return sc.SELinuxOptions },
+		func(o corev1.SELinuxOptions) bool { return len(seLinuxViolations(o, version)) == 0 },
+		func(o corev1.SELinuxOptions) string { return strings.Join(seLinuxViolations(o, version), ", ") }, false)
 }
 
-func Apparmor(options *pflag.FlagSet) []Finding {
-	var apparmor []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		// 默认值应该是apparmor已设置,所以我们只关心它是否明确设置为unconfined
-		if pod.Annotations != nil {
-			for key, val := range pod.Annotations {
-				if val == "unconfined" && strings.Split(key, "/")[0] == "container.apparmor.security.beta.kubernetes.io" {
-					p := Finding{Check: "Apparmor Disabled", Namespace: pod.Namespace, Pod: pod.Name}
-					apparmor = append(apparmor, p)
-				}
-			}
-		}
+// seLinuxViolations 返回 SELinux 选项中 version 不允许的字段和值
+func seLinuxViolations(o corev1.SELinuxOptions, version PSSVersion) []string {
+	var violations []string
+	if !allowedInVersion(allowedSELinuxTypes, o.Type, version) {
+		violations = append(violations, "type "+o.Type)
 	}
-	return apparmor
+	if o.User != "" {
+		violations = append(violations, "user "+o.User)
+	}
+	if o.Role != "" {
+		violations = append(violations, "role "+o.Role)
+	}
+	return violations
 }
 
-func Procmount(options *pflag.FlagSet) []Finding {
+func Procmount(pod *corev1.Pod) []Finding {
 	var unmaskedProc []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		for _, container := range pod.Spec.Containers {
-			unmask := container.SecurityContext != nil && container.SecurityContext.ProcMount != nil && *container.SecurityContext.ProcMount == "Unmasked"
-			if unmask {
-				p := Finding{Check: "Unmasked procmount", Namespace: pod.Namespace, Pod: pod.Name, Container: container.Name}
-				unmaskedProc = append(unmaskedProc, p)
-			}
-		}
-		for _, init_container := range pod.Spec.InitContainers {
-			unmask := init_container.SecurityContext != nil && init_container.SecurityContext.ProcMount != nil && *init_container.SecurityContext.ProcMount == "Unmasked"
-			if unmask {
-				p := Finding{Check: "Unmasked procmount", Namespace: pod.Namespace, Pod: pod.Name, Container: init_container.Name}
-				unmaskedProc = append(unmaskedProc, p)
-			}
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		sc := container.SecurityContext
+		if sc != nil && sc.ProcMount != nil && *sc.ProcMount == corev1.UnmaskedProcMount {
+			unmaskedProc = append(unmaskedProc, containerFinding(pod, container, containerType))
 		}
-		for _, eph_container := range pod.Spec.EphemeralContainers {
-			unmask := eph_container.SecurityContext != nil && eph_container.SecurityContext.ProcMount != nil && *eph_container.SecurityContext.ProcMount == "Unmasked"
-			if unmask {
-				p := Finding{Check: "Unmasked procmount", Namespace: pod.Namespace, Pod: pod.Name, Container: eph_container.Name}
-				unmaskedProc = append(unmaskedProc, p)
-			}
-		}
-	}
+	})
 	return unmaskedProc
 }
 
-func Sysctl(options *pflag.FlagSet) []Finding {
+func Sysctl(pod *corev1.Pod, version PSSVersion) []Finding {
 	var sysctls []Finding
-	pods := ConnectWithPods(options)
-	for _, pod := range pods.Items {
-		sysctl := pod.Spec.SecurityContext != nil && pod.Spec.SecurityContext.Sysctls != nil
-		if sysctl {
-			for _, sys := range pod.Spec.SecurityContext.Sysctls {
-				safe := []string{"kernel.shm_rmid_forced", "net.ipv4.ip_local_port_range", "net.ipv4.ip_unprivileged_port_start", "net.ipv4.tcp_syncookies", "net.ipv4.ping_group_range"}
-				safe_sys := false
-				for _, s := range safe {
-					if sys.Name == s {
-						safe_sys = true
-					}
-				}
-				if !safe_sys {
-					p := Finding{Check: "Unsafe Sysctl", Namespace: pod.Namespace, Sysctl: sys.Name}
-					sysctls = append(sysctls, p)
-				}
-			}
+	if pod.Spec.SecurityContext == nil {
+		return nil
+	}
+	for _, sys := range pod.Spec.SecurityContext.Sysctls {
+		if !allowedInVersion(safeSysctls, sys.Name, version) {
+			p := podFinding(pod)
+			p.Sysctl = sys.Name
+			sysctls = append(sysctls, p)
 		}
 	}
 	return sysctls
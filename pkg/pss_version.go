@@ -0,0 +1,213 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// MinPSSVersion 是支持的最早的 Pod Security Standards 版本，对应 Kubernetes 1.23
+const MinPSSVersion = 23
+
+// PSSVersion 是 Pod Security Standards 的版本，与 Kubernetes 1.<Minor> 一起发布，零值表示 latest
+type PSSVersion struct {
+	Minor int
+}
+
+// LatestPSSVersion 表示最新版本的 Pod Security Standards
+var LatestPSSVersion = PSSVersion{}
+
+// Latest 判断是否为 latest
+func (v PSSVersion) Latest() bool {
+	return v.Minor == 0
+}
+
+// AtLeast 判断该版本是否包含 Kubernetes 1.<minor> 引入的规则，latest 包含所有规则
+func (v PSSVersion) AtLeast(minor int) bool {
+	return v.Latest() || v.Minor >= minor
+}
+
+func (v PSSVersion) String() string {
+	if v.Latest() {
+		return "latest"
+	}
+	return fmt.Sprintf("v1.%d", v.Minor)
+}
+
+var pssVersionPattern = regexp.MustCompile(`^v?1\.([0-9]+)$`)
+
+// ParsePSSVersion 解析 latest、v1.29 或 1.29 形式的版本，与 enforce-version 标签的格式一致
+func ParsePSSVersion(s string) (PSSVersion, error) {
+	s = strings.TrimSpace(s)
+	if s == "latest" {
+		return LatestPSSVersion, nil
+	}
+	m := pssVersionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return PSSVersion{}, fmt.Errorf("invalid version %q (expected latest or v1.<minor>)", s)
+	}
+	minor, err := strconv.Atoi(m[1])
+	if err != nil || minor < MinPSSVersion {
+		return PSSVersion{}, fmt.Errorf("unsupported version %q (supported: v1.%d to latest)", s, MinPSSVersion)
+	}
+	return PSSVersion{Minor: minor}, nil
+}
+
+var serverMinorPattern = regexp.MustCompile(`^[0-9]+`)
+
+// ServerPSSVersion 通过 discovery 接口检测 API Server 的版本。托管集群的 minor 可能带有后缀(例如 29+)，
+// 早于 1.23 的集群按 v1.23 检查
+func ServerPSSVersion(client kubernetes.Interface) (PSSVersion, error) {
+	info, err := client.Discovery().ServerVersion()
+	if err != nil {
+		return LatestPSSVersion, err
+	}
+	minor, err := strconv.Atoi(serverMinorPattern.FindString(info.Minor))
+	if info.Major != "1" || err != nil {
+		return LatestPSSVersion, fmt.Errorf("unrecognized server version %s", info.GitVersion)
+	}
+	if minor < MinPSSVersion {
+		log.Warn().Msgf("集群版本 %s 早于 v1.%d，按 v1.%d 的 Pod Security Standards 检查", info.GitVersion, MinPSSVersion, MinPSSVersion)
+		minor = MinPSSVersion
+	}
+	return PSSVersion{Minor: minor}, nil
+}
+
+// namespaceVersion 返回命名空间通过 enforce-version 标签固定的版本，与 Pod Security Admission 一致，
+// 无效的版本按 latest 处理，同时返回解析错误
+func namespaceVersion(ns *corev1.Namespace) (version PSSVersion, pinned bool, err error) {
+	value, ok := ns.Labels[PSAEnforceVersionLabel]
+	if !ok {
+		return LatestPSSVersion, false, nil
+	}
+	version, err = ParsePSSVersion(value)
+	return version, true, err
+}
+
+// NamespaceVersions 返回通过 pod-security.kubernetes.io/enforce-version 标签固定了版本的命名空间，
+// 与 Pod Security Admission 一致，无效的版本按 latest 处理
+func NamespaceVersions(namespaces []corev1.Namespace) map[string]PSSVersion {
+	pinned := make(map[string]PSSVersion)
+	for i := range namespaces {
+		ns := &namespaces[i]
+		version, ok, err := namespaceVersion(ns)
+		if !ok {
+			continue
+		}
+		if err != nil {
+			log.Warn().Err(err).Msgf("命名空间 %s 的 %s 标签无效，按 latest 检查", ns.Name, PSAEnforceVersionLabel)
+		}
+		pinned[ns.Name] = version
+	}
+	return pinned
+}
+
+// PinnedVersions 查询集群中的命名空间，返回通过 enforce-version 标签固定了版本的命名空间
+func PinnedVersions(ctx context.Context, client kubernetes.Interface) (map[string]PSSVersion, error) {
+	list, err := client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return NamespaceVersions(list.Items), nil
+}
+
+// NamespaceVersionLister 通过 informer 缓存命名空间，供准入 Webhook 在每个请求中查询 enforce-version 标签，
+// 标签的变化无需重启即可生效
+type NamespaceVersionLister struct {
+	client kubernetes.Interface
+	lister corelisters.NamespaceLister
+}
+
+// namespaceSyncTimeout 限制等待命名空间 informer 首次同步的时间，没有 list/watch 命名空间权限时 informer 会一直重试
+const namespaceSyncTimeout = 30 * time.Second
+
+// WatchNamespaceVersions 启动命名空间 informer 并等待首次同步，ctx 结束时停止
+func WatchNamespaceVersions(ctx context.Context, client kubernetes.Interface) (*NamespaceVersionLister, error) {
+	factory := informers.NewSharedInformerFactory(client, 0)
+	namespaces := factory.Core().V1().Namespaces()
+	informer := namespaces.Informer()
+	factory.Start(ctx.Done())
+	syncCtx, cancel := context.WithTimeout(ctx, namespaceSyncTimeout)
+	defer cancel()
+	if !cache.WaitForCacheSync(syncCtx.Done(), informer.HasSynced) {
+		factory.Shutdown()
+		return nil, fmt.Errorf("等待命名空间 informer 同步失败")
+	}
+	return &NamespaceVersionLister{client: client, lister: namespaces.Lister()}, nil
+}
+
+// PinnedVersion 返回命名空间通过 enforce-version 标签固定的版本，没有固定时返回 false。
+// 缓存中还没有的命名空间(例如刚刚创建)直接向 API Server 查询，查询失败时按没有固定处理
+func (l *NamespaceVersionLister) PinnedVersion(namespace string) (PSSVersion, bool) {
+	ns, err := l.lister.Get(namespace)
+	if apierrors.IsNotFound(err) {
+		ns, err = l.client.CoreV1().Namespaces().Get(context.TODO(), namespace, metav1.GetOptions{})
+	}
+	if err != nil {
+		log.Debug().Err(err).Msgf("获取命名空间 %s 失败，忽略 %s 标签", namespace, PSAEnforceVersionLabel)
+		return LatestPSSVersion, false
+	}
+	version, ok, err := namespaceVersion(ns)
+	if err != nil {
+		log.Debug().Err(err).Msgf("命名空间 %s 的 %s 标签无效，按 latest 检查", namespace, PSAEnforceVersionLabel)
+	}
+	return version, ok
+}
+
+// 以下是随版本变化的规则，键或常量是引入该规则的 Kubernetes minor 版本。
+// 新版本的 Pod Security Standards 修改规则时只需要更新这里
+
+// safeSysctls 是 Baseline 允许的 sysctl
+var safeSysctls = map[string]int{
+	"kernel.shm_rmid_forced":              23,
+	"net.ipv4.ip_local_port_range":        23,
+	"net.ipv4.ip_unprivileged_port_start": 23,
+	"net.ipv4.tcp_syncookies":             23,
+	"net.ipv4.ping_group_range":           23,
+	"net.ipv4.ip_local_reserved_ports":    27,
+	"net.ipv4.tcp_keepalive_time":         29,
+	"net.ipv4.tcp_fin_timeout":            29,
+	"net.ipv4.tcp_keepalive_intvl":        29,
+	"net.ipv4.tcp_keepalive_probes":       29,
+	"net.ipv4.tcp_rmem":                   32,
+	"net.ipv4.tcp_wmem":                   32,
+}
+
+// allowedSELinuxTypes 是 Baseline 允许的 SELinux type，空字符串表示不设置
+var allowedSELinuxTypes = map[string]int{
+	"":                   23,
+	"container_t":        23,
+	"container_init_t":   23,
+	"container_kvm_t":    23,
+	"container_engine_t": 31,
+}
+
+const (
+	// windowsExemptSince 起 allowPrivilegeEscalation、capabilities 和 seccomp 的 Restricted 规则不再适用于 Windows Pod
+	windowsExemptSince = 25
+	// appArmorFieldSince 起 securityContext.appArmorProfile 字段和注解一起检查，之前只检查注解
+	appArmorFieldSince = 30
+)
+
+// allowedInVersion 判断 name 在 allowed 中且在 version 中已经引入
+func allowedInVersion(allowed map[string]int, name string, version PSSVersion) bool {
+	since, ok := allowed[name]
+	return ok && version.AtLeast(since)
+}
+
+// windowsExempt 判断 Pod 在 version 中是否不受 Linux 相关的 Restricted 规则约束
+func windowsExempt(pod *corev1.Pod, version PSSVersion) bool {
+	return version.AtLeast(windowsExemptSince) && windowsPod(pod)
+}
@@ -0,0 +1,155 @@
+package pkg
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParsePSSVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    PSSVersion
+		wantErr bool
+	}{
+		{in: "latest", want: LatestPSSVersion},
+		{in: " latest ", want: LatestPSSVersion},
+		{in: "v1.29", want: PSSVersion{Minor: 29}},
+		{in: "1.29", want: PSSVersion{Minor: 29}},
+		{in: "v1.23", want: PSSVersion{Minor: MinPSSVersion}},
+		{in: "v1.40", want: PSSVersion{Minor: 40}},
+		{in: "v1.22", wantErr: true},
+		{in: "v2.1", wantErr: true},
+		{in: "v1.29.3", wantErr: true},
+		{in: "1.x", wantErr: true},
+		{in: "Latest", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParsePSSVersion(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePSSVersion(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParsePSSVersion(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServerPSSVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		info    version.Info
+		want    PSSVersion
+		wantErr bool
+	}{
+		{name: "release", info: version.Info{Major: "1", Minor: "29", GitVersion: "v1.29.4"}, want: PSSVersion{Minor: 29}},
+		{name: "managed cluster suffix", info: version.Info{Major: "1", Minor: "30+", GitVersion: "v1.30.2-eks-1552ad0"}, want: PSSVersion{Minor: 30}},
+		{name: "older than v1.23", info: version.Info{Major: "1", Minor: "21", GitVersion: "v1.21.14"}, want: PSSVersion{Minor: MinPSSVersion}},
+		{name: "unknown major", info: version.Info{Major: "2", Minor: "0", GitVersion: "v2.0.0"}, want: LatestPSSVersion, wantErr: true},
+		{name: "empty minor", info: version.Info{Major: "1", GitVersion: "v1"}, want: LatestPSSVersion, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			client.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &tt.info
+			got, err := ServerPSSVersion(client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ServerPSSVersion error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ServerPSSVersion = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllowedInVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		version PSSVersion
+		want    bool
+	}{
+		{name: "net.ipv4.tcp_syncookies", version: PSSVersion{Minor: 23}, want: true},
+		{name: "net.ipv4.ip_local_reserved_ports", version: PSSVersion{Minor: 26}, want: false},
+		{name: "net.ipv4.ip_local_reserved_ports", version: PSSVersion{Minor: 27}, want: true},
+		{name: "net.ipv4.tcp_rmem", version: PSSVersion{Minor: 31}, want: false},
+		{name: "net.ipv4.tcp_rmem", version: LatestPSSVersion, want: true},
+		{name: "kernel.msgmax", version: LatestPSSVersion, want: false},
+	}
+	for _, tt := range tests {
+		if got := allowedInVersion(safeSysctls, tt.name, tt.version); got != tt.want {
+			t.Errorf("allowedInVersion(%s, %v) = %v, want %v", tt.name, tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestWindowsExempt(t *testing.T) {
+	pod := func(os corev1.OSName) *corev1.Pod {
+		p := &corev1.Pod{}
+		if os != "" {
+			p.Spec.OS = &corev1.PodOS{Name: os}
+		}
+		return p
+	}
+	tests := []struct {
+		name    string
+		pod     *corev1.Pod
+		version PSSVersion
+		want    bool
+	}{
+		{name: "windows latest", pod: pod(corev1.Windows), version: LatestPSSVersion, want: true},
+		{name: "windows v1.25", pod: pod(corev1.Windows), version: PSSVersion{Minor: windowsExemptSince}, want: true},
+		{name: "windows v1.24", pod: pod(corev1.Windows), version: PSSVersion{Minor: 24}, want: false},
+		{name: "linux", pod: pod(corev1.Linux), version: LatestPSSVersion, want: false},
+		{name: "os unset", pod: pod(""), version: LatestPSSVersion, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := windowsExempt(tt.pod, tt.version); got != tt.want {
+				t.Errorf("windowsExempt = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceVersionLister(t *testing.T) {
+	namespace := func(name, version string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if version != "" {
+			ns.Labels = map[string]string{PSAEnforceVersionLabel: version}
+		}
+		return ns
+	}
+	client := fake.NewSimpleClientset(namespace("pinned", "v1.27"), namespace("invalid", "v1.x"), namespace("default", ""))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lister, err := WatchNamespaceVersions(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		namespace string
+		want      PSSVersion
+		pinned    bool
+	}{
+		{namespace: "pinned", want: PSSVersion{Minor: 27}, pinned: true},
+		{namespace: "invalid", want: LatestPSSVersion, pinned: true},
+		{namespace: "default", want: LatestPSSVersion},
+		{namespace: "missing", want: LatestPSSVersion},
+	}
+	for _, tt := range tests {
+		got, pinned := lister.PinnedVersion(tt.namespace)
+		if got != tt.want || pinned != tt.pinned {
+			t.Errorf("PinnedVersion(%s) = %v, %v, want %v, %v", tt.namespace, got, pinned, tt.want, tt.pinned)
+		}
+	}
+}
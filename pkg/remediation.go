@@ -0,0 +1,367 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Fix 是修复一个问题需要对 Pod 模板做的一处修改
+type Fix struct {
+	ContainerType string   // 为空表示修改 Pod 本身
+	Container     string   // 容器名称
+	Path          []string // Container 为空时相对于 Pod，例如 spec.hostPID；否则相对于容器，例如 securityContext.privileged
+	Value         any      // 设置的值，nil 表示删除字段
+	Remove        []string // 不为空时从 Path 处的列表中删除这些元素，忽略 Value
+}
+
+// runtimeDefaultProfile 是 seccomp 和 AppArmor 修复后的 profile，同时删除只有 Localhost 才允许设置的 localhostProfile
+func runtimeDefaultProfile() map[string]any {
+	return map[string]any{"type": "RuntimeDefault", "localhostProfile": nil}
+}
+
+func podFix(path string, value any) Fix {
+	return Fix{Path: strings.Split(path, "."), Value: value}
+}
+
+func containerFix(f Finding, path string, value any) Fix {
+	return Fix{ContainerType: f.ContainerType, Container: f.Container, Path: strings.Split(path, "."), Value: value}
+}
+
+func annotationFix(key string, value any) Fix {
+	return Fix{Path: []string{"metadata", "annotations", key}, Value: value}
+}
+
+// securityContextFix 修改 Pod 和容器都可以设置的安全上下文字段：问题来自 Pod 级别(包括继承的 (pod) 和两级都没有设置的 unset)时
+// 修改 Pod 的安全上下文，否则修改容器的安全上下文
+func securityContextFix(f Finding, field string, value any) Fix {
+	if f.Container == "" || f.Value == "unset" || strings.HasSuffix(f.Value, " (pod)") {
+		return podFix("spec.securityContext."+field, value)
+	}
+	return containerFix(f, "securityContext."+field, value)
+}
+
+// Remediate 返回修复问题需要的修改。需要人工判断的问题，例如替换 hostPath 卷、移除主机端口、
+// 不安全的 sysctl 和自定义检查，以及创建后不能修改的临时容器，返回 nil
+func Remediate(f Finding) []Fix {
+	if f.ContainerType == ContainerTypeEphemeral {
+		return nil
+	}
+	switch f.Check {
+	case "hostpid":
+		return []Fix{podFix("spec.hostPID", false)}
+	case "hostnet":
+		return []Fix{podFix("spec.hostNetwork", false)}
+	case "hostipc":
+		return []Fix{podFix("spec.hostIPC", false)}
+	case "hostprocess":
+		return []Fix{securityContextFix(f, "windowsOptions.hostProcess", false)}
+	case "privileged":
+		return []Fix{containerFix(f, "securityContext.privileged", false)}
+	case "allowprivesc":
+		return []Fix{containerFix(f, "securityContext.allowPrivilegeEscalation", false)}
+	case "procmount":
+		return []Fix{containerFix(f, "securityContext.procMount", "Default")}
+	case "addedcaps":
+		fix := containerFix(f, "securityContext.capabilities.add", nil)
+		fix.Remove = f.Capabilities
+		return []Fix{fix}
+	case "restrictedcaps":
+		fixes := []Fix{containerFix(f, "securityContext.capabilities.drop", []any{"ALL"})}
+		if len(f.Capabilities) > 0 {
+			fix := containerFix(f, "securityContext.capabilities.add", nil)
+			fix.Remove = f.Capabilities
+			fixes = append(fixes, fix)
+		}
+		return fixes
+	case "seccomp", "seccompprofile":
+		return seccompFixes(f)
+	case "apparmor":
+		if strings.HasSuffix(f.Value, " (annotation)") {
+			return []Fix{annotationFix(apparmorAnnotationPrefix+f.Container, "runtime/default")}
+		}
+		return []Fix{securityContextFix(f, "appArmorProfile", runtimeDefaultProfile())}
+	case "selinux":
+		options := map[string]any{"user": nil, "role": nil}
+		if strings.Contains(f.Value, "type ") {
+			options["type"] = nil
+		}
+		return []Fix{securityContextFix(f, "seLinuxOptions", options)}
+	case "runasnonroot":
+		return []Fix{securityContextFix(f, "runAsNonRoot", true)}
+	case "runasuser":
+		fixes := []Fix{securityContextFix(f, "runAsUser", nil)}
+		// 删除容器的 runAsUser 后容器继承 Pod 级别的设置，Pod 级别同样是 0 时一起删除
+		if f.podAlsoInvalid {
+			fixes = append(fixes, podFix("spec.securityContext.runAsUser", nil))
+		}
+		return fixes
+	}
+	return nil
+}
+
+// seccompFixes 在生效的 profile 的来源处设置 RuntimeDefault。来源是已废弃的注解时同时删除注解，
+// 否则 API Server 会因为字段和注解不一致拒绝 Pod
+func seccompFixes(f Finding) []Fix {
+	switch {
+	case strings.HasSuffix(f.Value, "("+SeccompSourceContainerAnnotation+")"):
+		return []Fix{
+			containerFix(f, "securityContext.seccompProfile", runtimeDefaultProfile()),
+			annotationFix(seccompContainerAnnotationPrefix+f.Container, nil),
+		}
+	case strings.HasSuffix(f.Value, "("+SeccompSourceContainer+")"):
+		return []Fix{containerFix(f, "securityContext.seccompProfile", runtimeDefaultProfile())}
+	case strings.HasSuffix(f.Value, "("+SeccompSourcePodAnnotation+")"):
+		return []Fix{
+			podFix("spec.securityContext.seccompProfile", runtimeDefaultProfile()),
+			annotationFix(seccompPodAnnotation, nil),
+		}
+	}
+	// Pod 级别的字段或两级都没有设置
+	return []Fix{podFix("spec.securityContext.seccompProfile", runtimeDefaultProfile())}
+}
+
+// patchableKinds 是可以生成补丁的对象类型、apiVersion 和 Pod 模板的路径
+var patchableKinds = map[string]struct {
+	apiVersion string
+	template   []string
+}{
+	"Pod":                   {"v1", nil},
+	"ReplicationController": {"v1", []string{"spec", "template"}},
+	"Deployment":            {"apps/v1", []string{"spec", "template"}},
+	"StatefulSet":           {"apps/v1", []string{"spec", "template"}},
+	"DaemonSet":             {"apps/v1", []string{"spec", "template"}},
+	"ReplicaSet":            {"apps/v1", []string{"spec", "template"}},
+	"Job":                   {"batch/v1", []string{"spec", "template"}},
+	"CronJob":               {"batch/v1", []string{"spec", "jobTemplate", "spec", "template"}},
+}
+
+// WorkloadPatch 合并了一个工作负载上所有可以自动修复的问题
+type WorkloadPatch struct {
+	Target   ScannedObject
+	Fixes    []Fix
+	Findings []Finding // 补丁修复的问题
+}
+
+// BuildPatches 按问题所属的工作负载合并修复，返回补丁和无法自动修复的问题。
+// 补丁按工作负载在问题列表中第一次出现的顺序排列
+func BuildPatches(findings []Finding) (patches []WorkloadPatch, manual []Finding) {
+	index := make(map[string]int)
+	for _, f := range findings {
+		target := FindingObject(f)
+		fixes := Remediate(f)
+		if _, ok := patchableKinds[target.Kind]; !ok || fixes == nil {
+			manual = append(manual, f)
+			continue
+		}
+		key := target.key()
+		i, ok := index[key]
+		if !ok {
+			i = len(patches)
+			index[key] = i
+			patches = append(patches, WorkloadPatch{Target: target})
+		}
+		patches[i].Fixes = append(patches[i].Fixes, fixes...)
+		patches[i].Findings = append(patches[i].Findings, f)
+	}
+	return patches, manual
+}
+
+// containerListKeys 是各类容器在 Pod spec 中的字段名
+var containerListKeys = map[string]string{
+	ContainerTypeContainer: "containers",
+	ContainerTypeInit:      "initContainers",
+}
+
+// StrategicMergePatch 返回补丁的内容：容器按名称合并，capabilities 用 $deleteFromPrimitiveList 指令删除，
+// 值为 nil 的字段会被删除
+func (p WorkloadPatch) StrategicMergePatch() map[string]any {
+	kind := patchableKinds[p.Target.Kind]
+	metadata := map[string]any{"name": p.Target.Name}
+	if p.Target.Namespace != "" {
+		metadata["namespace"] = p.Target.Namespace
+	}
+	patch := map[string]any{"apiVersion": kind.apiVersion, "kind": p.Target.Kind, "metadata": metadata}
+	template := patch
+	for _, key := range kind.template {
+		template = childMap(template, key)
+	}
+
+	containers := make(map[string]map[string]any)
+	for _, fix := range p.Fixes {
+		target := template
+		if fix.Container != "" {
+			key := fix.ContainerType + "/" + fix.Container
+			if target = containers[key]; target == nil {
+				target = map[string]any{"name": fix.Container}
+				containers[key] = target
+				spec := childMap(template, "spec")
+				listKey := containerListKeys[fix.ContainerType]
+				list, _ := spec[listKey].([]any)
+				spec[listKey] = append(list, target)
+			}
+		}
+		parent := target
+		for _, key := range fix.Path[:len(fix.Path)-1] {
+			parent = childMap(parent, key)
+		}
+		setPatchValue(parent, fix)
+	}
+	return patch
+}
+
+// childMap 返回 m[key]，不存在时创建
+func childMap(m map[string]any, key string) map[string]any {
+	child, ok := m[key].(map[string]any)
+	if !ok {
+		child = make(map[string]any)
+		m[key] = child
+	}
+	return child
+}
+
+// setPatchValue 在 parent 中写入一处修改。多个问题修改同一个字段时，map 的值合并，删除的列表元素去重合并
+func setPatchValue(parent map[string]any, fix Fix) {
+	key := fix.Path[len(fix.Path)-1]
+	if len(fix.Remove) > 0 {
+		key = "$deleteFromPrimitiveList/" + key
+		list, _ := parent[key].([]any)
+		for _, item := range fix.Remove {
+			if !containsValue(list, item) {
+				list = append(list, item)
+			}
+		}
+		parent[key] = list
+		return
+	}
+	if value, ok := fix.Value.(map[string]any); ok {
+		if existing, ok := parent[key].(map[string]any); ok {
+			for k, v := range value {
+				existing[k] = v
+			}
+			return
+		}
+		copied := make(map[string]any, len(value))
+		for k, v := range value {
+			copied[k] = v
+		}
+		parent[key] = copied
+		return
+	}
+	parent[key] = fix.Value
+}
+
+func containsValue(list []any, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Marshal 返回补丁的 YAML，开头的注释列出补丁修复的问题
+func (p WorkloadPatch) Marshal() ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Fixes for %s\n", p.Target.String())
+	for _, f := range p.Findings {
+		line := "# - " + f.Check
+		if f.Container != "" {
+			line += " : container " + f.Container
+		}
+		if details := f.Details(); details != "" {
+			line += " : " + details
+		}
+		buf.WriteString(line + "\n")
+	}
+	if err := encodeYAML(&buf, p.StrategicMergePatch()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeYAML(w io.Writer, v any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// kustomizeComponent 是引用所有补丁的 kustomize Component
+type kustomizeComponent struct {
+	APIVersion string           `yaml:"apiVersion"`
+	Kind       string           `yaml:"kind"`
+	Patches    []kustomizePatch `yaml:"patches"`
+}
+
+type kustomizePatch struct {
+	Path string `yaml:"path"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// patchFileName 返回补丁的文件名，例如 prod-deployment-web.yaml
+func patchFileName(target ScannedObject) string {
+	name := strings.ToLower(target.Kind) + "-" + target.Name
+	if target.Namespace != "" {
+		name = target.Namespace + "-" + name
+	}
+	return unsafeFileChars.ReplaceAllString(name, "_")
+}
+
+// WritePatchSet 把每个工作负载的补丁写入 dir 中单独的文件，并生成引用这些补丁的 kustomization.yaml(kind: Component)。
+// 多集群扫描时每个集群写入以集群名称命名的子目录
+func WritePatchSet(dir string, patches []WorkloadPatch) error {
+	components := make(map[string]*kustomizeComponent)
+	var order []string
+	used := make(map[string]bool)
+	for _, p := range patches {
+		subdir := dir
+		if p.Target.Cluster != "" {
+			subdir = filepath.Join(dir, unsafeFileChars.ReplaceAllString(p.Target.Cluster, "_"))
+		}
+		if err := os.MkdirAll(subdir, 0o755); err != nil {
+			return err
+		}
+		// 清单中可能有同名的对象，重名时追加序号
+		base := patchFileName(p.Target)
+		file := base + ".yaml"
+		for n := 2; used[filepath.Join(subdir, file)]; n++ {
+			file = fmt.Sprintf("%s-%d.yaml", base, n)
+		}
+		used[filepath.Join(subdir, file)] = true
+
+		data, err := p.Marshal()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(subdir, file), data, 0o644); err != nil {
+			return err
+		}
+		c, ok := components[subdir]
+		if !ok {
+			c = &kustomizeComponent{APIVersion: "kustomize.config.k8s.io/v1alpha1", Kind: "Component"}
+			components[subdir] = c
+			order = append(order, subdir)
+		}
+		c.Patches = append(c.Patches, kustomizePatch{Path: file})
+	}
+	for _, subdir := range order {
+		var buf bytes.Buffer
+		if err := encodeYAML(&buf, components[subdir]); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(subdir, "kustomization.yaml"), buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
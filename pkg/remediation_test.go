@@ -0,0 +1,302 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// deploymentFinding 返回 prod/Deployment/web 中 app 容器上的问题，value 为空时返回 Pod 级别的问题
+func deploymentFinding(check, value string) Finding {
+	f := Finding{Check: check, Namespace: "prod", Pod: "web-7d9f", OwnerKind: "Deployment", OwnerName: "web", Value: value}
+	f.Container, f.ContainerType = "app", ContainerTypeContainer
+	return f
+}
+
+func podLevel(f Finding) Finding {
+	f.Container, f.ContainerType = "", ""
+	return f
+}
+
+// deploymentPatch 返回 prod/Deployment/web 的补丁，template 是 spec.template 的内容
+func deploymentPatch(template map[string]any) map[string]any {
+	return map[string]any{
+		"apiVersion": "apps/v1",
+		"kind":       "Deployment",
+		"metadata":   map[string]any{"name": "web", "namespace": "prod"},
+		"spec":       map[string]any{"template": template},
+	}
+}
+
+func podSpecPatch(spec map[string]any) map[string]any {
+	return map[string]any{"spec": spec}
+}
+
+func appPatch(fields map[string]any) map[string]any {
+	fields["name"] = "app"
+	return podSpecPatch(map[string]any{"containers": []any{fields}})
+}
+
+func appSecurityContext(fields map[string]any) map[string]any {
+	return appPatch(map[string]any{"securityContext": fields})
+}
+
+func podSecurityContext(fields map[string]any) map[string]any {
+	return podSpecPatch(map[string]any{"securityContext": fields})
+}
+
+func runtimeDefault() map[string]any {
+	return map[string]any{"type": "RuntimeDefault", "localhostProfile": nil}
+}
+
+func TestStrategicMergePatch(t *testing.T) {
+	addedCaps := deploymentFinding("addedcaps", "")
+	addedCaps.Capabilities = []string{"NET_ADMIN", "SYS_TIME"}
+	restrictedDrop := deploymentFinding("restrictedcaps", "drop ALL missing")
+	restrictedAdd := deploymentFinding("restrictedcaps", "drop ALL missing")
+	restrictedAdd.Capabilities = []string{"CHOWN"}
+	podRunAsUser := deploymentFinding("runasuser", "0")
+	podRunAsUser.podAlsoInvalid = true
+
+	tests := []struct {
+		name    string
+		finding Finding
+		want    map[string]any // spec.template 的内容
+	}{
+		{"hostpid", podLevel(deploymentFinding("hostpid", "")), podSpecPatch(map[string]any{"hostPID": false})},
+		{"hostnet", podLevel(deploymentFinding("hostnet", "")), podSpecPatch(map[string]any{"hostNetwork": false})},
+		{"hostipc", podLevel(deploymentFinding("hostipc", "")), podSpecPatch(map[string]any{"hostIPC": false})},
+		{"hostprocess on pod", podLevel(deploymentFinding("hostprocess", "")),
+			podSecurityContext(map[string]any{"windowsOptions": map[string]any{"hostProcess": false}})},
+		{"hostprocess on container", deploymentFinding("hostprocess", "true"),
+			appSecurityContext(map[string]any{"windowsOptions": map[string]any{"hostProcess": false}})},
+		{"privileged", deploymentFinding("privileged", ""), appSecurityContext(map[string]any{"privileged": false})},
+		{"allowprivesc", deploymentFinding("allowprivesc", ""), appSecurityContext(map[string]any{"allowPrivilegeEscalation": false})},
+		{"procmount", deploymentFinding("procmount", "Unmasked"), appSecurityContext(map[string]any{"procMount": "Default"})},
+		{"addedcaps", addedCaps, appSecurityContext(map[string]any{
+			"capabilities": map[string]any{"$deleteFromPrimitiveList/add": []any{"NET_ADMIN", "SYS_TIME"}}})},
+		{"restrictedcaps drop", restrictedDrop, appSecurityContext(map[string]any{
+			"capabilities": map[string]any{"drop": []any{"ALL"}}})},
+		{"restrictedcaps drop and add", restrictedAdd, appSecurityContext(map[string]any{
+			"capabilities": map[string]any{"drop": []any{"ALL"}, "$deleteFromPrimitiveList/add": []any{"CHOWN"}}})},
+		{"seccomp container field", deploymentFinding("seccomp", "Unconfined (container)"),
+			appSecurityContext(map[string]any{"seccompProfile": runtimeDefault()})},
+		{"seccomp container annotation", deploymentFinding("seccomp", "Unconfined (container annotation)"), map[string]any{
+			"metadata": map[string]any{"annotations": map[string]any{"container.seccomp.security.alpha.kubernetes.io/app": nil}},
+			"spec": map[string]any{"containers": []any{map[string]any{
+				"name": "app", "securityContext": map[string]any{"seccompProfile": runtimeDefault()}}}},
+		}},
+		{"seccomp pod field", deploymentFinding("seccomp", "Unconfined (pod)"),
+			podSecurityContext(map[string]any{"seccompProfile": runtimeDefault()})},
+		{"seccomp pod annotation", deploymentFinding("seccomp", "Unconfined (pod annotation)"), map[string]any{
+			"metadata": map[string]any{"annotations": map[string]any{"seccomp.security.alpha.kubernetes.io/pod": nil}},
+			"spec":     map[string]any{"securityContext": map[string]any{"seccompProfile": runtimeDefault()}},
+		}},
+		{"seccompprofile unset", deploymentFinding("seccompprofile", "unset"),
+			podSecurityContext(map[string]any{"seccompProfile": runtimeDefault()})},
+		{"apparmor field", deploymentFinding("apparmor", "Unconfined"),
+			appSecurityContext(map[string]any{"appArmorProfile": runtimeDefault()})},
+		{"apparmor annotation", deploymentFinding("apparmor", "unconfined (annotation)"), map[string]any{
+			"metadata": map[string]any{"annotations": map[string]any{"container.apparmor.security.beta.kubernetes.io/app": "runtime/default"}},
+		}},
+		{"selinux user and role", deploymentFinding("selinux", "user system_u"),
+			appSecurityContext(map[string]any{"seLinuxOptions": map[string]any{"user": nil, "role": nil}})},
+		{"selinux type", deploymentFinding("selinux", "type spc_t (pod)"),
+			podSecurityContext(map[string]any{"seLinuxOptions": map[string]any{"user": nil, "role": nil, "type": nil}})},
+		{"runasnonroot unset", deploymentFinding("runasnonroot", "unset"), podSecurityContext(map[string]any{"runAsNonRoot": true})},
+		{"runasnonroot container", deploymentFinding("runasnonroot", "false"), appSecurityContext(map[string]any{"runAsNonRoot": true})},
+		{"runasuser container", deploymentFinding("runasuser", "0"), appSecurityContext(map[string]any{"runAsUser": nil})},
+		{"runasuser inherited", deploymentFinding("runasuser", "0 (pod)"), podSecurityContext(map[string]any{"runAsUser": nil})},
+		{"runasuser container and pod", podRunAsUser, map[string]any{
+			"spec": map[string]any{
+				"containers":      []any{map[string]any{"name": "app", "securityContext": map[string]any{"runAsUser": nil}}},
+				"securityContext": map[string]any{"runAsUser": nil},
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patches, manual := BuildPatches([]Finding{tt.finding})
+			if len(manual) > 0 || len(patches) != 1 {
+				t.Fatalf("got %d patches and %d manual findings, want 1 patch", len(patches), len(manual))
+			}
+			if got, want := patches[0].StrategicMergePatch(), deploymentPatch(tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("patch = %#v\nwant %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestRemediateRunAsUserFromPod(t *testing.T) {
+	root := int64(0)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+		Spec: corev1.PodSpec{
+			SecurityContext: &corev1.PodSecurityContext{RunAsUser: &root},
+			Containers: []corev1.Container{
+				{Name: "app", SecurityContext: &corev1.SecurityContext{RunAsUser: &root}},
+			},
+		},
+	}
+	// 容器和 Pod 上各有一个问题，容器上的修复同时删除 Pod 级别的设置
+	check, _ := LookupCheck("runasuser")
+	findings := EvaluatePod(pod, []Check{check})
+	if len(findings) != 2 || findings[0].Container != "app" {
+		t.Fatalf("findings = %v, want one on app and one on the pod", findings)
+	}
+	want := []Fix{
+		{ContainerType: ContainerTypeContainer, Container: "app", Path: []string{"securityContext", "runAsUser"}},
+		{Path: []string{"spec", "securityContext", "runAsUser"}},
+	}
+	if got := Remediate(findings[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("Remediate = %#v\nwant %#v", got, want)
+	}
+}
+
+func TestBuildPatches(t *testing.T) {
+	privileged := deploymentFinding("privileged", "")
+	hostpid := podLevel(deploymentFinding("hostpid", ""))
+	firstCaps := deploymentFinding("addedcaps", "")
+	firstCaps.Capabilities = []string{"NET_ADMIN"}
+	secondCaps := deploymentFinding("restrictedcaps", "drop ALL missing")
+	secondCaps.Capabilities = []string{"NET_ADMIN", "CHOWN"}
+	hostpath := deploymentFinding("hostpath", "")
+	ephemeral := deploymentFinding("privileged", "")
+	ephemeral.ContainerType = ContainerTypeEphemeral
+	rollout := Finding{Check: "hostpid", Namespace: "prod", Pod: "canary-1", OwnerKind: "Rollout", OwnerName: "canary"}
+	cron := Finding{Check: "hostnet", Namespace: "prod", Pod: "report-1", OwnerKind: "CronJob", OwnerName: "report"}
+
+	patches, manual := BuildPatches([]Finding{privileged, hostpath, cron, hostpid, firstCaps, secondCaps, ephemeral, rollout})
+	if want := []Finding{hostpath, ephemeral, rollout}; !reflect.DeepEqual(manual, want) {
+		t.Errorf("manual = %v, want %v", manual, want)
+	}
+	if len(patches) != 2 {
+		t.Fatalf("got %d patches, want 2", len(patches))
+	}
+
+	// 同一工作负载的问题合并到一个补丁，删除的 capabilities 去重
+	web := patches[0]
+	if want := []Finding{privileged, hostpid, firstCaps, secondCaps}; !reflect.DeepEqual(web.Findings, want) {
+		t.Errorf("web findings = %v, want %v", web.Findings, want)
+	}
+	wantWeb := deploymentPatch(map[string]any{"spec": map[string]any{
+		"hostPID": false,
+		"containers": []any{map[string]any{"name": "app", "securityContext": map[string]any{
+			"privileged": false,
+			"capabilities": map[string]any{
+				"$deleteFromPrimitiveList/add": []any{"NET_ADMIN", "CHOWN"},
+				"drop":                         []any{"ALL"},
+			},
+		}}},
+	}})
+	if got := web.StrategicMergePatch(); !reflect.DeepEqual(got, wantWeb) {
+		t.Errorf("web patch = %#v\nwant %#v", got, wantWeb)
+	}
+
+	wantCron := map[string]any{
+		"apiVersion": "batch/v1",
+		"kind":       "CronJob",
+		"metadata":   map[string]any{"name": "report", "namespace": "prod"},
+		"spec": map[string]any{"jobTemplate": map[string]any{"spec": map[string]any{"template": map[string]any{
+			"spec": map[string]any{"hostNetwork": false},
+		}}}},
+	}
+	if got := patches[1].StrategicMergePatch(); !reflect.DeepEqual(got, wantCron) {
+		t.Errorf("cron patch = %#v\nwant %#v", got, wantCron)
+	}
+}
+
+func TestWritePatchSet(t *testing.T) {
+	first := deploymentFinding("privileged", "")
+	first.Source = &ManifestSource{File: "a.yaml"}
+	second := deploymentFinding("privileged", "")
+	second.Source = &ManifestSource{File: "b.yaml"} // 同名对象，文件名追加序号
+	pod := Finding{Check: "hostpid", Pod: "debug", Kind: "Pod"}
+	remote := deploymentFinding("privileged", "")
+	remote.Cluster = "eu/west-1"
+
+	patches, manual := BuildPatches([]Finding{first, second, pod, remote})
+	if len(manual) > 0 || len(patches) != 4 {
+		t.Fatalf("got %d patches and %d manual findings, want 4 patches", len(patches), len(manual))
+	}
+	dir := t.TempDir()
+	if err := WritePatchSet(dir, patches); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"kustomization.yaml": `apiVersion: kustomize.config.k8s.io/v1alpha1
+kind: Component
+patches:
+  - path: prod-deployment-web.yaml
+  - path: prod-deployment-web-2.yaml
+  - path: pod-debug.yaml
+`,
+		"prod-deployment-web.yaml": `# Fixes for prod/Deployment/web
+# - privileged : container app
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  template:
+    spec:
+      containers:
+        - name: app
+          securityContext:
+            privileged: false
+`,
+		"pod-debug.yaml": `# Fixes for Pod/debug
+# - hostpid
+apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  hostPID: false
+`,
+		filepath.Join("eu_west-1", "kustomization.yaml"): `apiVersion: kustomize.config.k8s.io/v1alpha1
+kind: Component
+patches:
+  - path: prod-deployment-web.yaml
+`,
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s =\n%s\nwant\n%s", name, got, want)
+		}
+	}
+
+	var written []string
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			written = append(written, rel)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join("eu_west-1", "kustomization.yaml"),
+		filepath.Join("eu_west-1", "prod-deployment-web.yaml"),
+		"kustomization.yaml",
+		"pod-debug.yaml",
+		"prod-deployment-web-2.yaml",
+		"prod-deployment-web.yaml",
+	}
+	if !reflect.DeepEqual(written, want) {
+		t.Errorf("files = %v, want %v", written, want)
+	}
+}
@@ -0,0 +1,432 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ReportSchemaVersion 是 JSON 和 YAML 报告的格式版本，字段只增不改
+const ReportSchemaVersion = "v1"
+
+// 报告的输出格式
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatCSV      = "csv"
+	FormatTable    = "table"
+	FormatMarkdown = "markdown"
+	FormatSARIF    = "sarif"
+	FormatJUnit    = "junit"
+)
+
+// ReportFormats 是 WriteReport 支持的所有格式
+var ReportFormats = []string{FormatText, FormatJSON, FormatYAML, FormatCSV, FormatTable, FormatMarkdown, FormatSARIF, FormatJUnit}
+
+// Report 是 JSON 和 YAML 报告的顶层结构
+type Report struct {
+	SchemaVersion       string              `json:"schema_version" yaml:"schema_version"`
+	Findings            []ReportRecord      `json:"findings" yaml:"findings"`
+	Suppressed          []ReportRecord      `json:"suppressed,omitempty" yaml:"suppressed,omitempty"`
+	ExpiredSuppressions []RecordSuppression `json:"expired_suppressions,omitempty" yaml:"expired_suppressions,omitempty"`
+	Capabilities        []CapabilityRecord  `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+}
+
+// CapabilityRecord 是一个容器的 capabilities 状态，status 为 compliant 或 violating
+type CapabilityRecord struct {
+	Cluster       string   `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	Namespace     string   `json:"namespace" yaml:"namespace"`
+	Object        string   `json:"object" yaml:"object"` // 例如 Deployment/web
+	Container     string   `json:"container" yaml:"container"`
+	ContainerType string   `json:"container_type" yaml:"container_type"`
+	Status        string   `json:"status" yaml:"status"`
+	Violations    []string `json:"violations,omitempty" yaml:"violations,omitempty"` // 违反的检查 ID
+}
+
+// 容器的 capabilities 状态
+const (
+	CapabilityCompliant = "compliant"
+	CapabilityViolating = "violating"
+)
+
+// CapabilityRecords 把 CapabilityStatuses 的结果转换为报告记录
+func CapabilityRecords(result ScanResult) []CapabilityRecord {
+	var records []CapabilityRecord
+	for _, s := range CapabilityStatuses(result) {
+		r := CapabilityRecord{
+			Cluster:       s.Object.Cluster,
+			Namespace:     s.Object.Namespace,
+			Object:        s.Object.Kind + "/" + s.Object.Name,
+			Container:     s.Container.Name,
+			ContainerType: s.Container.Type,
+			Status:        CapabilityCompliant,
+			Violations:    s.Violations,
+		}
+		if !s.Compliant() {
+			r.Status = CapabilityViolating
+		}
+		records = append(records, r)
+	}
+	return records
+}
+
+// state 返回状态和违反的检查，例如 violating (addedcaps)
+func (r CapabilityRecord) state() string {
+	if len(r.Violations) == 0 {
+		return r.Status
+	}
+	return r.Status + " (" + strings.Join(r.Violations, ",") + ")"
+}
+
+// ReportRecord 是一条问题在机器可读报告中的稳定表示，字段名不随文本报告的措辞变化
+type ReportRecord struct {
+	Fingerprint   string             `json:"fingerprint" yaml:"fingerprint"` // 跨扫描稳定的问题标识，见 Fingerprint
+	CheckID       string             `json:"check_id" yaml:"check_id"`
+	Title         string             `json:"title" yaml:"title"`
+	Severity      Severity           `json:"severity" yaml:"severity"`
+	Level         Level              `json:"level" yaml:"level"`
+	Cluster       string             `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+	Namespace     string             `json:"namespace" yaml:"namespace"`
+	Kind          string             `json:"kind,omitempty" yaml:"kind,omitempty"`
+	OwnerKind     string             `json:"owner_kind,omitempty" yaml:"owner_kind,omitempty"`
+	OwnerName     string             `json:"owner_name,omitempty" yaml:"owner_name,omitempty"`
+	Replicas      int                `json:"replicas,omitempty" yaml:"replicas,omitempty"`
+	Pod           string             `json:"pod" yaml:"pod"`
+	Container     string             `json:"container,omitempty" yaml:"container,omitempty"`
+	ContainerType string             `json:"container_type,omitempty" yaml:"container_type,omitempty"`
+	Image         string             `json:"image,omitempty" yaml:"image,omitempty"`
+	Details       string             `json:"details,omitempty" yaml:"details,omitempty"`
+	Capabilities  []string           `json:"capabilities,omitempty" yaml:"capabilities,omitempty"`
+	HostPort      int                `json:"host_port,omitempty" yaml:"host_port,omitempty"`
+	Volume        string             `json:"volume,omitempty" yaml:"volume,omitempty"`
+	Path          string             `json:"path,omitempty" yaml:"path,omitempty"`
+	Sysctl        string             `json:"sysctl,omitempty" yaml:"sysctl,omitempty"`
+	Value         string             `json:"value,omitempty" yaml:"value,omitempty"`
+	Source        *RecordLocation    `json:"source,omitempty" yaml:"source,omitempty"`
+	Suppression   *RecordSuppression `json:"suppression,omitempty" yaml:"suppression,omitempty"`
+}
+
+// RecordLocation 是清单中问题的来源位置，Document 从 0 开始计数
+type RecordLocation struct {
+	File     string `json:"file" yaml:"file"`
+	Document int    `json:"document" yaml:"document"`
+	Line     int    `json:"line" yaml:"line"`
+}
+
+// RecordSuppression 是报告中的抑制规则
+type RecordSuppression struct {
+	Source    string `json:"source" yaml:"source"`
+	Check     string `json:"check" yaml:"check"`
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty" yaml:"pod,omitempty"`
+	Container string `json:"container,omitempty" yaml:"container,omitempty"`
+	Path      string `json:"path,omitempty" yaml:"path,omitempty"`
+	Reason    string `json:"reason" yaml:"reason"`
+	Owner     string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	Expires   string `json:"expires,omitempty" yaml:"expires,omitempty"`
+	Expired   bool   `json:"expired,omitempty" yaml:"expired,omitempty"`
+}
+
+func newRecordSuppression(s *Suppression) *RecordSuppression {
+	return &RecordSuppression{
+		Source: s.Source, Check: s.Check, Namespace: s.Namespace, Pod: s.Pod, Container: s.Container, Path: s.Path,
+		Reason: s.Reason, Owner: s.Owner, Expires: s.Expires, Expired: s.Expired,
+	}
+}
+
+// NewReportRecord 把问题转换为报告记录
+func NewReportRecord(f Finding) ReportRecord {
+	r := ReportRecord{
+		CheckID:       f.Check,
+		Title:         f.Check,
+		Severity:      f.Severity,
+		Level:         f.Level,
+		Cluster:       f.Cluster,
+		Namespace:     f.Namespace,
+		Kind:          f.Kind,
+		OwnerKind:     f.OwnerKind,
+		OwnerName:     f.OwnerName,
+		Replicas:      f.Replicas,
+		Pod:           f.Pod,
+		Container:     f.Container,
+		ContainerType: f.ContainerType,
+		Image:         f.Image,
+		Details:       f.Details(),
+		Capabilities:  f.Capabilities,
+		HostPort:      f.Hostport,
+		Volume:        f.Volume,
+		Path:          f.Path,
+		Sysctl:        f.Sysctl,
+		Value:         f.Value,
+	}
+	if c, ok := LookupCheck(f.Check); ok {
+		r.Title = c.Title()
+	}
+	if f.Source != nil {
+		r.Source = &RecordLocation{File: f.Source.File, Document: f.Source.Document, Line: f.Source.Line}
+	}
+	if f.Suppression != nil {
+		r.Suppression = newRecordSuppression(f.Suppression)
+	}
+	r.Fingerprint = Fingerprint(r)
+	return r
+}
+
+// ReportRecords 按检查的注册顺序转换所有问题，同一检查内保持扫描顺序
+func ReportRecords(findings []Finding) []ReportRecord {
+	order := make(map[string]int)
+	for i, c := range Checks() {
+		order[c.ID()] = i
+	}
+	sorted := make([]Finding, len(findings))
+	copy(sorted, findings)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return order[sorted[i].Check] < order[sorted[j].Check]
+	})
+	records := make([]ReportRecord, 0, len(sorted))
+	for _, f := range sorted {
+		records = append(records, NewReportRecord(f))
+	}
+	return records
+}
+
+// NewReport 构造 JSON 和 YAML 报告
+func NewReport(result ScanResult) Report {
+	report := Report{
+		SchemaVersion: ReportSchemaVersion,
+		Findings:      ReportRecords(result.Findings),
+	}
+	if len(result.Suppressed) > 0 {
+		report.Suppressed = ReportRecords(result.Suppressed)
+	}
+	for i := range result.ExpiredSuppressions {
+		report.ExpiredSuppressions = append(report.ExpiredSuppressions, *newRecordSuppression(&result.ExpiredSuppressions[i]))
+	}
+	report.Capabilities = CapabilityRecords(result)
+	return report
+}
+
+// ReportOptions 控制 WriteReport 的输出内容
+type ReportOptions struct {
+	// ShowSuppressed 把被抑制和豁免的问题与其他问题一起列出并加以标记，而不是只在文本报告末尾单独列出。
+	// JSON/YAML 报告总是在 suppressed 中包含它们，SARIF 报告总是用 suppressions 字段标记它们
+	ShowSuppressed bool
+}
+
+// WriteReport 以指定格式把扫描结果写入 w，每个问题只输出一次。CSV、table、markdown 和 JUnit 默认只包含未被抑制的问题，
+// SARIF 中被抑制的问题带有 suppressions 字段
+func WriteReport(w io.Writer, format string, result ScanResult, opts ReportOptions) error {
+	findings := result.Findings
+	if opts.ShowSuppressed {
+		findings = append(append([]Finding(nil), findings...), result.Suppressed...)
+	}
+	switch format {
+	case FormatText, "":
+		writeTextReport(w, findings, result.checks())
+		writeCapabilityReport(w, CapabilityRecords(result))
+		if opts.ShowSuppressed {
+			// 被抑制的问题已经在各检查下列出并标记，末尾只列出过期的规则
+			result.Suppressed = nil
+		}
+		writeSuppressionReport(w, result)
+		return nil
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(NewReport(result))
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(NewReport(result)); err != nil {
+			return err
+		}
+		return enc.Close()
+	case FormatCSV:
+		return writeCSVReport(w, ReportRecords(findings))
+	case FormatTable:
+		if err := writeTableReport(w, ReportRecords(findings)); err != nil {
+			return err
+		}
+		return writeCapabilityTable(w, CapabilityRecords(result))
+	case FormatMarkdown:
+		if err := writeMarkdownReport(w, ReportRecords(findings)); err != nil {
+			return err
+		}
+		return writeCapabilityMarkdown(w, CapabilityRecords(result))
+	case FormatSARIF:
+		return WriteSARIF(w, append(append([]Finding(nil), result.Findings...), result.Suppressed...))
+	case FormatJUnit:
+		if !opts.ShowSuppressed {
+			result.Suppressed = nil
+		}
+		return WriteJUnit(w, result)
+	}
+	return fmt.Errorf("unknown output format %q (supported: %s)", format, strings.Join(ReportFormats, ", "))
+}
+
+// csvHeader 是 CSV 报告的列，顺序固定
+var csvHeader = []string{
+	"check_id", "severity", "level", "cluster", "namespace", "kind", "owner_kind", "owner_name", "replicas",
+	"pod", "container", "container_type", "image", "details", "capabilities", "host_port", "volume", "path",
+	"sysctl", "source_file", "source_document", "source_line", "value", "suppression",
+}
+
+func writeCSVReport(w io.Writer, records []ReportRecord) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		var file, document, line string
+		if r.Source != nil {
+			file, document, line = r.Source.File, strconv.Itoa(r.Source.Document), strconv.Itoa(r.Source.Line)
+		}
+		row := []string{
+			r.CheckID, string(r.Severity), string(r.Level), r.Cluster, r.Namespace, r.Kind, r.OwnerKind, r.OwnerName, optionalInt(r.Replicas),
+			r.Pod, r.Container, r.ContainerType, r.Image, r.Details, strings.Join(r.Capabilities, ","), optionalInt(r.HostPort), r.Volume, r.Path,
+			r.Sysctl, file, document, line, r.Value, r.suppressionState(),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func optionalInt(v int) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.Itoa(v)
+}
+
+// tableColumns 是 table 和 markdown 报告的列
+var tableColumns = []string{"CHECK", "SEVERITY", "LEVEL", "NAMESPACE", "OBJECT", "CONTAINER", "DETAILS"}
+
+func tableRow(r ReportRecord) []string {
+	object := "Pod/" + r.Pod
+	switch {
+	case r.OwnerKind != "":
+		object = r.OwnerKind + "/" + r.OwnerName
+	case r.Kind != "":
+		object = r.Kind + "/" + r.Pod
+	}
+	namespace := r.Namespace
+	if r.Cluster != "" {
+		namespace = r.Cluster + "/" + namespace
+	}
+	details := r.Details
+	if state := r.suppressionState(); state != "" {
+		details = strings.TrimSpace(details + " (" + state + ")")
+	}
+	return []string{r.CheckID, string(r.Severity), string(r.Level), namespace, object, r.Container, details}
+}
+
+// suppressionState 返回 exempted(注解豁免)、suppressed(抑制规则)，没有生效的抑制时返回空字符串
+func (r ReportRecord) suppressionState() string {
+	switch {
+	case r.Suppression == nil || r.Suppression.Expired:
+		return ""
+	case r.Suppression.Source == SuppressionSourceAnnotation:
+		return "exempted"
+	default:
+		return "suppressed"
+	}
+}
+
+func writeTableReport(w io.Writer, records []ReportRecord) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(tableColumns, "\t"))
+	for _, r := range records {
+		fmt.Fprintln(tw, strings.Join(tableRow(r), "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeMarkdownReport(w io.Writer, records []ReportRecord) error {
+	fmt.Fprintf(w, "| %s |\n", strings.Join(tableColumns, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat("---|", len(tableColumns)))
+	for _, r := range records {
+		cells := tableRow(r)
+		for i, cell := range cells {
+			cells[i] = strings.ReplaceAll(cell, "|", `\|`)
+		}
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// capabilityColumns 是 table 和 markdown 报告中 capabilities 状态表的列
+var capabilityColumns = []string{"NAMESPACE", "OBJECT", "CONTAINER", "CAPABILITIES"}
+
+func capabilityRow(r CapabilityRecord) []string {
+	namespace := r.Namespace
+	if r.Cluster != "" {
+		namespace = r.Cluster + "/" + namespace
+	}
+	container := r.Container
+	if r.ContainerType != ContainerTypeContainer {
+		container += " (" + r.ContainerType + ")"
+	}
+	return []string{namespace, r.Object, container, r.state()}
+}
+
+// writeCapabilityReport 在文本报告中列出每个容器的 capabilities 状态
+func writeCapabilityReport(w io.Writer, records []CapabilityRecord) {
+	if len(records) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "Capabilities status per container")
+	for _, r := range records {
+		kind, name, _ := strings.Cut(r.Object, "/")
+		line := fmt.Sprintf("namespace %s : %s %s : %s %s : %s", r.Namespace, strings.ToLower(kind), name, r.ContainerType, r.Container, r.state())
+		if r.Cluster != "" {
+			line = "cluster " + r.Cluster + " : " + line
+		}
+		fmt.Fprintln(w, line)
+	}
+	fmt.Fprintln(w, "")
+}
+
+// writeCapabilityTable 在问题表之后空一行输出 capabilities 状态表
+func writeCapabilityTable(w io.Writer, records []CapabilityRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	fmt.Fprintln(w, "")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(capabilityColumns, "\t"))
+	for _, r := range records {
+		fmt.Fprintln(tw, strings.Join(capabilityRow(r), "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeCapabilityMarkdown(w io.Writer, records []CapabilityRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	fmt.Fprintf(w, "\n| %s |\n", strings.Join(capabilityColumns, " | "))
+	fmt.Fprintf(w, "|%s\n", strings.Repeat("---|", len(capabilityColumns)))
+	for _, r := range records {
+		cells := capabilityRow(r)
+		for i, cell := range cells {
+			cells[i] = strings.ReplaceAll(cell, "|", `\|`)
+		}
+		if _, err := fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,38 +2,184 @@ package pkg
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
 
-func ReportPSS(f []Finding, check string) {
-	var rep *os.File
-	rep = os.Stdout
-	fmt.Fprintf(rep, "Findings for the %s check\n", check)
-	if f != nil {
-		for _, i := range f {
-			switch i.Check {
-			case "hostpid", "hostnet", "hostipc", "privileged", "allowprivesc", "HostProcess", "Seccomp Disabled", "Unmasked Procmount", "Apparmor Disabled":
-				if i.Container != "" {
-					fmt.Fprintf(rep, "namespace %s : pod %s : container %s\n", i.Namespace, i.Pod, i.Container)
-				} else {
-					fmt.Fprintf(rep, "namespace %s : pod %s\n", i.Namespace, i.Pod)
-				}
-			case "Added Capabilities":
-				fmt.Fprintf(rep, "namespace %s : pod %s : container %s added capabilities %s \n", i.Namespace, i.Pod, i.Container, strings.Join(i.Capabilities[:], ","))
-			case "Dropped Capabilities":
-				fmt.Fprintf(rep, "namespace %s : pod %s : container %s dropped capabilities %s \n", i.Namespace, i.Pod, i.Container, strings.Join(i.Capabilities[:], ","))
-			case "Host Ports":
-				fmt.Fprintf(rep, "namespace %s : pod %s : container %s : port %d\n", i.Namespace, i.Pod, i.Container, i.Hostport)
-			case "Host Path":
-				fmt.Fprintf(rep, "namespace %s : pod %s : volume %s : path %s\n", i.Namespace, i.Pod, i.Volume, i.Path)
-			case "Unsafe Sysctl":
-				fmt.Fprintf(rep, "namespace %s : pod %s : unsafe sysctl %s", i.Namespace, i.Pod, i.Sysctl)
-
-			}
+// ReportPSS 按注册顺序逐条检查把发现的问题输出到标准输出
+func ReportPSS(findings []Finding) {
+	WriteTextReport(os.Stdout, findings)
+}
+
+// WriteTextReport 按注册顺序逐条检查把发现的问题以文本形式写入 w
+func WriteTextReport(w io.Writer, findings []Finding) {
+	writeTextReport(w, findings, Checks())
+}
+
+func writeTextReport(w io.Writer, findings []Finding, checks []Check) {
+	for _, c := range checks {
+		reportCheck(w, findings, c)
+	}
+}
+
+func reportCheck(rep io.Writer, findings []Finding, check Check) {
+	fmt.Fprintf(rep, "Findings for the %s check\n", check.Title())
+	found := false
+	for _, i := range findings {
+		if i.Check != check.ID() {
+			continue
 		}
-	} else {
+		found = true
+		fmt.Fprintln(rep, FormatFinding(i))
+	}
+	if !found {
 		fmt.Fprintln(rep, "No findings!")
 	}
 	fmt.Fprintln(rep, "")
 }
+
+// FormatFinding 把问题格式化为一行文本，包含集群、命名空间、所属对象、容器和具体内容
+func FormatFinding(f Finding) string {
+	line := fmt.Sprintf("namespace %s : %s", f.Namespace, subject(f))
+	if f.Cluster != "" {
+		line = fmt.Sprintf("cluster %s : %s", f.Cluster, line)
+	}
+	if f.Container != "" {
+		line += fmt.Sprintf(" : container %s", f.Container)
+	}
+	if details := f.Details(); details != "" {
+		line += " : " + details
+	}
+	if notes := findingNotes(f); len(notes) > 0 {
+		line += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return line
+}
+
+// Details 返回问题的具体内容，例如端口号或挂载路径，没有额外内容的检查返回空字符串
+func (f Finding) Details() string {
+	switch f.Check {
+	case "addedcaps":
+		return "added capabilities " + strings.Join(f.Capabilities, ",")
+	case "hostports":
+		return fmt.Sprintf("port %d", f.Hostport)
+	case "hostpath":
+		return fmt.Sprintf("volume %s : path %s", f.Volume, f.Path)
+	case "sysctl":
+		return "unsafe sysctl " + f.Sysctl
+	case "apparmor":
+		return "apparmor profile " + f.Value
+	case "selinux":
+		return "seLinuxOptions " + f.Value
+	case "runasnonroot":
+		return "runAsNonRoot " + f.Value
+	case "runasuser":
+		return "runAsUser " + f.Value
+	case "seccomp", "seccompprofile":
+		return "seccomp profile " + f.Value
+	case "volumetypes":
+		return fmt.Sprintf("volume %s : type %s", f.Volume, f.Value)
+	case "restrictedcaps":
+		var details []string
+		if len(f.Capabilities) > 0 {
+			details = append(details, "added capabilities "+strings.Join(f.Capabilities, ","))
+		}
+		if f.Value != "" {
+			details = append(details, f.Value)
+		}
+		return strings.Join(details, " : ")
+	}
+	return ""
+}
+
+// subject 返回报告中问题所属对象的显示名称：优先显示顶层控制器，其次是清单中的对象类型，最后是 Pod
+func subject(f Finding) string {
+	switch {
+	case f.OwnerKind != "":
+		return strings.ToLower(f.OwnerKind) + " " + f.OwnerName
+	case f.Kind != "":
+		return strings.ToLower(f.Kind) + " " + f.Pod
+	default:
+		return "pod " + f.Pod
+	}
+}
+
+// findingNotes 返回附加在报告行末尾的补充信息
+func findingNotes(f Finding) []string {
+	var notes []string
+	if f.OwnerKind != "" && f.Pod != f.OwnerName {
+		notes = append(notes, "pod "+f.Pod)
+	}
+	if f.Replicas > 1 {
+		notes = append(notes, fmt.Sprintf("%d replicas", f.Replicas))
+	}
+	if f.Source != nil {
+		notes = append(notes, fmt.Sprintf("%s document %d line %d", f.Source.File, f.Source.Document, f.Source.Line))
+	}
+	switch {
+	case f.Suppressed() && f.Suppression.Source == SuppressionSourceAnnotation:
+		notes = append(notes, "exempted: "+exemptReason(f.Suppression))
+	case f.Suppressed():
+		notes = append(notes, "suppressed: "+f.Suppression.Reason)
+	case f.Suppression != nil:
+		notes = append(notes, "suppression expired "+f.Suppression.Expires)
+	}
+	return notes
+}
+
+// writeSuppressionReport 在文本报告末尾列出通过注解豁免的问题、被抑制规则匹配的问题和已过期的抑制规则
+func writeSuppressionReport(w io.Writer, result ScanResult) {
+	var exempted, suppressed []Finding
+	for _, f := range result.Suppressed {
+		if f.Suppression.Source == SuppressionSourceAnnotation {
+			exempted = append(exempted, f)
+		} else {
+			suppressed = append(suppressed, f)
+		}
+	}
+	if len(exempted) > 0 {
+		fmt.Fprintln(w, "Exempted findings")
+		for _, f := range exempted {
+			fmt.Fprintln(w, TitledFinding(f))
+		}
+		fmt.Fprintln(w, "")
+	}
+	if len(suppressed) > 0 {
+		fmt.Fprintln(w, "Suppressed findings")
+		for _, f := range suppressed {
+			fmt.Fprintf(w, "%s : owner %s%s\n", TitledFinding(f), f.Suppression.Owner, expiresNote(f.Suppression))
+		}
+		fmt.Fprintln(w, "")
+	}
+	if len(result.ExpiredSuppressions) > 0 {
+		fmt.Fprintln(w, "Expired suppressions")
+		for i := range result.ExpiredSuppressions {
+			s := &result.ExpiredSuppressions[i]
+			fmt.Fprintf(w, "%s : reason %s : owner %s%s\n", s.String(), s.Reason, s.Owner, expiresNote(s))
+		}
+		fmt.Fprintln(w, "")
+	}
+}
+
+// TitledFinding 在问题前加上检查名称，用于不按检查分组的列表
+func TitledFinding(f Finding) string {
+	if c, ok := LookupCheck(f.Check); ok {
+		return c.Title() + " : " + FormatFinding(f)
+	}
+	return FormatFinding(f)
+}
+
+func exemptReason(s *Suppression) string {
+	if s.Reason == "" {
+		return "no reason given"
+	}
+	return s.Reason
+}
+
+func expiresNote(s *Suppression) string {
+	if s.Expires == "" {
+		return ""
+	}
+	return " : expires " + s.Expires
+}
@@ -0,0 +1,151 @@
+package pkg
+
+import (
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// restrictedVolumeTypes 是 Restricted 级别允许的卷类型
+var restrictedVolumeTypes = []string{"configMap", "csi", "downwardAPI", "emptyDir", "ephemeral", "persistentVolumeClaim", "projected", "secret"}
+
+func init() {
+	Register(NewCheck(CheckInfo{
+		ID:          "runasnonroot",
+		Title:       "Run As Non-Root",
+		Level:       LevelRestricted,
+		Severity:    SeverityMedium,
+		Description: "容器没有要求以非 root 用户运行，镜像默认使用 root 时容器进程就是 root",
+		Remediation: "将 spec.securityContext.runAsNonRoot 设置为 true，容器中不要覆盖为 false",
+	}, RunAsNonRoot))
+	Register(NewCheck(CheckInfo{
+		ID:          "runasuser",
+		Title:       "Run As Root User",
+		Level:       LevelRestricted,
+		Severity:    SeverityMedium,
+		Description: "Pod 或容器显式以 UID 0(root)运行",
+		Remediation: "删除 runAsUser: 0，或设置为非 0 的 UID",
+	}, RunAsUser))
+	Register(NewVersionedCheck(CheckInfo{
+		ID:          "restrictedcaps",
+		Title:       "Restricted Capabilities",
+		Level:       LevelRestricted,
+		Severity:    SeverityMedium,
+		Description: "容器没有移除全部 Linux capabilities，或者添加了 NET_BIND_SERVICE 之外的 capabilities",
+		Remediation: "设置 securityContext.capabilities.drop: [ALL]，add 中只保留 NET_BIND_SERVICE",
+	}, RestrictedCapabilities))
+	Register(NewVersionedCheck(CheckInfo{
+		ID:          "seccompprofile",
+		Title:       "Seccomp Profile Not Set",
+		Level:       LevelRestricted,
+		Severity:    SeverityMedium,
+		Description: "容器生效的 seccomp profile 不是 RuntimeDefault 或指定了 localhostProfile 的 Localhost",
+		Remediation: "将 spec.securityContext.seccompProfile.type 设置为 RuntimeDefault",
+	}, SeccompProfile))
+	Register(NewCheck(CheckInfo{
+		ID:          "volumetypes",
+		Title:       "Restricted Volume Types",
+		Level:       LevelRestricted,
+		Severity:    SeverityMedium,
+		Description: "Pod 使用了 Restricted 级别不允许的卷类型，只允许 " + strings.Join(restrictedVolumeTypes, "、"),
+		Remediation: "改用 ConfigMap、Secret、emptyDir、PVC 等允许的卷类型",
+	}, VolumeTypes))
+}
+
+// windowsPod 判断 Pod 是否声明为 Windows Pod，Restricted 级别中与 Linux 相关的检查不适用于 Windows Pod
+func windowsPod(pod *corev1.Pod) bool {
+	return pod.Spec.OS != nil && pod.Spec.OS.Name == corev1.Windows
+}
+
+// mergedField 按 Pod Security Standards 的方式检查可以同时在 Pod 和容器安全上下文中设置的字段。
+// 容器设置的值覆盖 Pod 级别；容器没有设置时继承 Pod 级别，继承的非法值报告在容器上并标记 (pod)，
+// requireSet 为 true 时两级都没有设置的容器按 unset 报告。所有容器都覆盖了 Pod 级别的非法值时，
+// Pod 级别的值仍然违反标准，单独报告在 Pod 上
+func mergedField[T any](pod *corev1.Pod, podValue *T, containerValue func(sc *corev1.SecurityContext) *T,
+	allowed func(T) bool, format func(T) string, requireSet bool) []Finding {
+	var findings []Finding
+	podInvalid := podValue != nil && !allowed(*podValue)
+	inherited := false
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		var value *T
+		if container.SecurityContext != nil {
+			value = containerValue(container.SecurityContext)
+		}
+		p := containerFinding(pod, container, containerType)
+		switch {
+		case value != nil:
+			if allowed(*value) {
+				return
+			}
+			p.Value = format(*value)
+			p.podAlsoInvalid = podInvalid
+		case podInvalid:
+			inherited = true
+			p.Value = format(*podValue) + " (pod)"
+		case podValue == nil && requireSet:
+			p.Value = "unset"
+		default:
+			return
+		}
+		findings = append(findings, p)
+	})
+	if podInvalid && !inherited {
+		p := podFinding(pod)
+		p.Value = format(*podValue)
+		findings = append(findings, p)
+	}
+	return findings
+}
+
+func RunAsNonRoot(pod *corev1.Pod) []Finding {
+	var podValue *bool
+	if psc := pod.Spec.SecurityContext; psc != nil {
+		podValue = psc.RunAsNonRoot
+	}
+	return mergedField(pod, podValue,
+		func(sc *corev1.SecurityContext) *bool { return sc.RunAsNonRoot },
+		func(v bool) bool { return v },
+		strconv.FormatBool, true)
+}
+
+func RunAsUser(pod *corev1.Pod) []Finding {
+	var podValue *int64
+	if psc := pod.Spec.SecurityContext; psc != nil {
+		podValue = psc.RunAsUser
+	}
+	return mergedField(pod, podValue,
+		func(sc *corev1.SecurityContext) *int64 { return sc.RunAsUser },
+		func(v int64) bool { return v != 0 },
+		func(v int64) string { return strconv.FormatInt(v, 10) }, false)
+}
+
+func VolumeTypes(pod *corev1.Pod) []Finding {
+	var findings []Finding
+	for i := range pod.Spec.Volumes {
+		vol := &pod.Spec.Volumes[i]
+		volumeType := volumeSourceType(&vol.VolumeSource)
+		if slices.Contains(restrictedVolumeTypes, volumeType) {
+			continue
+		}
+		p := podFinding(pod)
+		p.Volume = vol.Name
+		p.Value = volumeType
+		findings = append(findings, p)
+	}
+	return findings
+}
+
+// volumeSourceType 返回卷类型在 API 中的字段名，例如 hostPath 或 nfs
+func volumeSourceType(source *corev1.VolumeSource) string {
+	v := reflect.ValueOf(source).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if field := v.Field(i); field.Kind() == reflect.Pointer && !field.IsNil() {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+			return name
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,186 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// fieldResult 是问题中与字段合并相关的部分，container 为 类型/名称，Pod 级别的问题为空
+type fieldResult struct {
+	container      string
+	value          string
+	podAlsoInvalid bool
+}
+
+func fieldResults(findings []Finding) []fieldResult {
+	var results []fieldResult
+	for _, f := range findings {
+		r := fieldResult{value: f.Value, podAlsoInvalid: f.podAlsoInvalid}
+		if f.Container != "" {
+			r.container = f.ContainerType + "/" + f.Container
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// securityContextPod 返回包含 app、sidecar 两个容器和 init 初始化容器的 Pod，
+// containers 中的安全上下文按 app、sidecar、init 的顺序设置，nil 表示不设置
+func securityContextPod(podSC *corev1.PodSecurityContext, containers ...*corev1.SecurityContext) *corev1.Pod {
+	pod := &corev1.Pod{Spec: corev1.PodSpec{
+		SecurityContext: podSC,
+		Containers:      []corev1.Container{{Name: "app"}, {Name: "sidecar"}},
+		InitContainers:  []corev1.Container{{Name: "init"}},
+	}}
+	all := []*corev1.Container{&pod.Spec.Containers[0], &pod.Spec.Containers[1], &pod.Spec.InitContainers[0]}
+	for i, sc := range containers {
+		all[i].SecurityContext = sc
+	}
+	return pod
+}
+
+func nonRoot(v bool) *corev1.SecurityContext { return &corev1.SecurityContext{RunAsNonRoot: &v} }
+
+func podNonRoot(v bool) *corev1.PodSecurityContext {
+	return &corev1.PodSecurityContext{RunAsNonRoot: &v}
+}
+
+func uid(v int64) *corev1.SecurityContext { return &corev1.SecurityContext{RunAsUser: &v} }
+
+func podUID(v int64) *corev1.PodSecurityContext { return &corev1.PodSecurityContext{RunAsUser: &v} }
+
+func TestRunAsNonRoot(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want []fieldResult
+	}{
+		{name: "unset everywhere", pod: securityContextPod(nil), want: []fieldResult{
+			{container: "container/app", value: "unset"},
+			{container: "container/sidecar", value: "unset"},
+			{container: "initContainer/init", value: "unset"},
+		}},
+		{name: "empty security contexts", pod: securityContextPod(&corev1.PodSecurityContext{}, &corev1.SecurityContext{}), want: []fieldResult{
+			{container: "container/app", value: "unset"},
+			{container: "container/sidecar", value: "unset"},
+			{container: "initContainer/init", value: "unset"},
+		}},
+		{name: "set on pod", pod: securityContextPod(podNonRoot(true))},
+		{name: "set on every container", pod: securityContextPod(nil, nonRoot(true), nonRoot(true), nonRoot(true))},
+		{name: "some containers unset", pod: securityContextPod(nil, nonRoot(true)), want: []fieldResult{
+			{container: "container/sidecar", value: "unset"},
+			{container: "initContainer/init", value: "unset"},
+		}},
+		{name: "container overrides pod with false", pod: securityContextPod(podNonRoot(true), nil, nonRoot(false)), want: []fieldResult{
+			{container: "container/sidecar", value: "false"},
+		}},
+		{name: "false inherited from pod", pod: securityContextPod(podNonRoot(false), nonRoot(true)), want: []fieldResult{
+			{container: "container/sidecar", value: "false (pod)"},
+			{container: "initContainer/init", value: "false (pod)"},
+		}},
+		{name: "every container overrides false pod", pod: securityContextPod(podNonRoot(false), nonRoot(true), nonRoot(true), nonRoot(true)), want: []fieldResult{
+			{value: "false"},
+		}},
+		{name: "false on pod and container", pod: securityContextPod(podNonRoot(false), nonRoot(false), nonRoot(true), nonRoot(true)), want: []fieldResult{
+			{container: "container/app", value: "false", podAlsoInvalid: true},
+			{value: "false"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldResults(RunAsNonRoot(tt.pod)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RunAsNonRoot = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunAsUser(t *testing.T) {
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want []fieldResult
+	}{
+		{name: "unset everywhere", pod: securityContextPod(nil)},
+		{name: "non-root on pod", pod: securityContextPod(podUID(1000))},
+		{name: "root inherited from pod", pod: securityContextPod(podUID(0), uid(1000)), want: []fieldResult{
+			{container: "container/sidecar", value: "0 (pod)"},
+			{container: "initContainer/init", value: "0 (pod)"},
+		}},
+		{name: "root on container", pod: securityContextPod(podUID(1000), nil, nil, uid(0)), want: []fieldResult{
+			{container: "initContainer/init", value: "0"},
+		}},
+		{name: "root on container without pod value", pod: securityContextPod(nil, uid(0)), want: []fieldResult{
+			{container: "container/app", value: "0"},
+		}},
+		{name: "every container overrides root pod", pod: securityContextPod(podUID(0), uid(1000), uid(1001), uid(1002)), want: []fieldResult{
+			{value: "0"},
+		}},
+		{name: "root on pod and container", pod: securityContextPod(podUID(0), uid(0), uid(1000), uid(1000)), want: []fieldResult{
+			{container: "container/app", value: "0", podAlsoInvalid: true},
+			{value: "0"},
+		}},
+		{name: "other container fields do not override", pod: securityContextPod(podUID(0), nonRoot(true), uid(1000), uid(1000)), want: []fieldResult{
+			{container: "container/app", value: "0 (pod)"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldResults(RunAsUser(tt.pod)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("RunAsUser = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunAsUserEphemeralContainer(t *testing.T) {
+	pod := securityContextPod(podUID(0), uid(1000), uid(1000), uid(1000))
+	pod.Spec.EphemeralContainers = []corev1.EphemeralContainer{{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debug"}}}
+	want := []fieldResult{{container: "ephemeralContainer/debug", value: "0 (pod)"}}
+	if got := fieldResults(RunAsUser(pod)); !reflect.DeepEqual(got, want) {
+		t.Errorf("RunAsUser = %+v, want %+v", got, want)
+	}
+}
+
+func TestVolumeTypes(t *testing.T) {
+	allowed := []corev1.VolumeSource{
+		{ConfigMap: &corev1.ConfigMapVolumeSource{}},
+		{CSI: &corev1.CSIVolumeSource{}},
+		{DownwardAPI: &corev1.DownwardAPIVolumeSource{}},
+		{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		{Ephemeral: &corev1.EphemeralVolumeSource{}},
+		{PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{}},
+		{Projected: &corev1.ProjectedVolumeSource{}},
+		{Secret: &corev1.SecretVolumeSource{}},
+	}
+	disallowed := []struct {
+		source corev1.VolumeSource
+		want   string
+	}{
+		{corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/run"}}, "hostPath"},
+		{corev1.VolumeSource{NFS: &corev1.NFSVolumeSource{}}, "nfs"},
+		{corev1.VolumeSource{GCEPersistentDisk: &corev1.GCEPersistentDiskVolumeSource{}}, "gcePersistentDisk"},
+		{corev1.VolumeSource{ISCSI: &corev1.ISCSIVolumeSource{}}, "iscsi"},
+		{corev1.VolumeSource{}, "unknown"},
+	}
+
+	pod := &corev1.Pod{}
+	for i, source := range allowed {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{Name: "allowed-" + string(rune('a'+i)), VolumeSource: source})
+	}
+	if findings := VolumeTypes(pod); len(findings) > 0 {
+		t.Fatalf("allowed volume types reported: %+v", findings)
+	}
+
+	var want []Finding
+	for i, tt := range disallowed {
+		name := "disallowed-" + string(rune('a'+i))
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{Name: name, VolumeSource: tt.source})
+		want = append(want, Finding{Volume: name, Value: tt.want})
+	}
+	if got := VolumeTypes(pod); !reflect.DeepEqual(got, want) {
+		t.Errorf("VolumeTypes = %+v, want %+v", got, want)
+	}
+}
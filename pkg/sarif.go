@@ -0,0 +1,245 @@
+package pkg
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	pssHelpURI   = "https://kubernetes.io/docs/concepts/security/pod-security-standards/"
+
+	sarifFingerprintKey = "getNoPSS/v1"
+)
+
+// 以下类型只包含 getNoPSS 用到的 SARIF 2.1.0 字段
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string              `json:"id"`
+	Name                 string              `json:"name"`
+	ShortDescription     sarifMessage        `json:"shortDescription"`
+	FullDescription      sarifMessage        `json:"fullDescription"`
+	Help                 sarifMessage        `json:"help"`
+	HelpURI              string              `json:"helpUri"`
+	DefaultConfiguration sarifConfiguration  `json:"defaultConfiguration"`
+	Properties           sarifRuleProperties `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifRuleProperties struct {
+	Tags             []string `json:"tags"`
+	SecuritySeverity string   `json:"security-severity"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID       string             `json:"ruleId"`
+	RuleIndex    int                `json:"ruleIndex"`
+	Level        string             `json:"level"`
+	Message      sarifMessage       `json:"message"`
+	Locations    []sarifLocation    `json:"locations"`
+	Suppressions []sarifSuppression `json:"suppressions,omitempty"`
+	// 代码扫描平台用 partialFingerprints 跨次扫描跟踪同一条结果
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Properties          ReportRecord      `json:"properties"`
+}
+
+type sarifSuppression struct {
+	Kind          string `json:"kind"`
+	Status        string `json:"status"`
+	Justification string `json:"justification"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel 把严重程度映射为 SARIF 的结果级别
+func sarifLevel(sev Severity) string {
+	switch sev {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// securitySeverity 是代码扫描平台用于排序的 0-10 分值
+func securitySeverity(sev Severity) string {
+	switch sev {
+	case SeverityCritical:
+		return "9.5"
+	case SeverityHigh:
+		return "8.0"
+	case SeverityMedium:
+		return "5.5"
+	case SeverityLow:
+		return "3.0"
+	default:
+		return "0.0"
+	}
+}
+
+// WriteSARIF 把问题写为 SARIF 2.1.0 报告：每个已注册的检查是一条规则，每个问题是一条结果。
+// 清单中的问题使用文件和行号定位，集群中的问题使用 namespace/pod/container 的逻辑位置。
+// 被抑制的问题带有 suppressions 字段，代码扫描平台会把它们显示为已忽略
+func WriteSARIF(w io.Writer, findings []Finding) error {
+	checks := Checks()
+	ruleIndex := make(map[string]int, len(checks))
+	rules := make([]sarifRule, 0, len(checks))
+	for i, c := range checks {
+		ruleIndex[c.ID()] = i
+		rules = append(rules, sarifRule{
+			ID:                   c.ID(),
+			Name:                 strings.ReplaceAll(c.Title(), " ", ""),
+			ShortDescription:     sarifMessage{Text: c.Title()},
+			FullDescription:      sarifMessage{Text: c.Description()},
+			Help:                 sarifMessage{Text: c.Remediation()},
+			HelpURI:              pssHelpURI,
+			DefaultConfiguration: sarifConfiguration{Level: sarifLevel(c.Severity())},
+			Properties: sarifRuleProperties{
+				Tags:             []string{"security", "pod-security-standards", string(c.Level())},
+				SecuritySeverity: securitySeverity(c.Severity()),
+			},
+		})
+	}
+
+	results := make([]sarifResult, 0, len(findings))
+	for _, r := range ReportRecords(findings) {
+		index, ok := ruleIndex[r.CheckID]
+		if !ok {
+			continue
+		}
+		message := r.Title + ": " + strings.Join(sarifSubject(r), "/")
+		if r.Details != "" {
+			message += ": " + r.Details
+		}
+		result := sarifResult{
+			RuleID:              r.CheckID,
+			RuleIndex:           index,
+			Level:               sarifLevel(r.Severity),
+			Message:             sarifMessage{Text: message},
+			Locations:           []sarifLocation{sarifLocationOf(r)},
+			PartialFingerprints: map[string]string{sarifFingerprintKey: r.Fingerprint},
+			Properties:          r,
+		}
+		if r.Suppression != nil && !r.Suppression.Expired {
+			result.Suppressions = []sarifSuppression{{Kind: "external", Status: "accepted", Justification: r.Suppression.Reason}}
+		}
+		results = append(results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool:    sarifTool{Driver: sarifDriver{Name: "getNoPSS", Rules: rules}},
+			Results: results,
+		}},
+	})
+}
+
+// sarifSubject 返回问题的逻辑位置：集群、命名空间、所属对象和容器
+func sarifSubject(r ReportRecord) []string {
+	var parts []string
+	if r.Cluster != "" {
+		parts = append(parts, r.Cluster)
+	}
+	if r.Namespace != "" {
+		parts = append(parts, r.Namespace)
+	}
+	switch {
+	case r.OwnerKind != "":
+		parts = append(parts, r.OwnerKind+"/"+r.OwnerName)
+	case r.Kind != "":
+		parts = append(parts, r.Kind+"/"+r.Pod)
+	default:
+		parts = append(parts, "Pod/"+r.Pod)
+	}
+	if r.Container != "" {
+		parts = append(parts, r.Container)
+	}
+	return parts
+}
+
+func sarifLocationOf(r ReportRecord) sarifLocation {
+	subject := sarifSubject(r)
+	loc := sarifLocation{LogicalLocations: []sarifLogicalLocation{{
+		Name:               subject[len(subject)-1],
+		FullyQualifiedName: strings.Join(subject, "/"),
+		Kind:               "resource",
+	}}}
+	if r.Source != nil && r.Source.File != "-" {
+		loc.PhysicalLocation = &sarifPhysicalLocation{
+			ArtifactLocation: sarifArtifactLocation{URI: sarifURI(r.Source.File)},
+		}
+		if r.Source.Line > 0 {
+			loc.PhysicalLocation.Region = &sarifRegion{StartLine: r.Source.Line}
+		}
+	}
+	return loc
+}
+
+// sarifURI 把文件路径转换为 SARIF 的 URI，相对路径保持相对于扫描时的工作目录
+func sarifURI(path string) string {
+	uri := filepath.ToSlash(path)
+	if filepath.IsAbs(path) {
+		if !strings.HasPrefix(uri, "/") {
+			uri = "/" + uri
+		}
+		return "file://" + uri
+	}
+	return strings.TrimPrefix(uri, "./")
+}
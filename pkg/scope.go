@@ -0,0 +1,181 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NamespacePattern 匹配命名空间名称，支持精确匹配、glob(如 team-*)和以 re: 开头的正则表达式
+type NamespacePattern struct {
+	raw   string
+	glob  bool
+	regex *regexp.Regexp
+}
+
+// ParseNamespacePattern 解析一个命名空间匹配规则
+func ParseNamespacePattern(pattern string) (NamespacePattern, error) {
+	p := NamespacePattern{raw: pattern}
+	switch {
+	case strings.HasPrefix(pattern, "re:"):
+		// 正则表达式需要完整匹配命名空间名称
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(pattern, "re:") + ")$")
+		if err != nil {
+			return p, fmt.Errorf("invalid namespace regex %q: %w", pattern, err)
+		}
+		p.regex = re
+	case strings.ContainsAny(pattern, "*?["):
+		if _, err := path.Match(pattern, ""); err != nil {
+			return p, fmt.Errorf("invalid namespace glob %q: %w", pattern, err)
+		}
+		p.glob = true
+	}
+	return p, nil
+}
+
+// Match 判断命名空间是否匹配该规则
+func (p NamespacePattern) Match(namespace string) bool {
+	switch {
+	case p.regex != nil:
+		return p.regex.MatchString(namespace)
+	case p.glob:
+		ok, _ := path.Match(p.raw, namespace)
+		return ok
+	default:
+		return p.raw == namespace
+	}
+}
+
+func (p NamespacePattern) exact() bool {
+	return p.regex == nil && !p.glob
+}
+
+func (p NamespacePattern) String() string {
+	return p.raw
+}
+
+// Scope 描述扫描范围：命名空间的包含、排除规则和标签选择器，以及传给 List 请求的 Pod 标签和字段选择器
+type Scope struct {
+	Include           []NamespacePattern
+	Exclude           []NamespacePattern
+	NamespaceSelector string // 按命名空间标签选择，例如 team=payments
+	LabelSelector     string // Pod 标签选择器
+	FieldSelector     string // Pod 字段选择器
+	PageSize          int64  // 分页获取 Pod 时每页的数量，0 表示使用 DefaultPageSize
+}
+
+// ScopeFromFlags 从 --namespace/--include、--exclude、--namespace-selector、--selector、--field-selector 和 --page-size 构造扫描范围
+func ScopeFromFlags(options *pflag.FlagSet) (*Scope, error) {
+	scope := &Scope{}
+	namespaces, _ := options.GetStringSlice("namespace")
+	include, _ := options.GetStringSlice("include")
+	for _, raw := range append(namespaces, include...) {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		p, err := ParseNamespacePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		scope.Include = append(scope.Include, p)
+	}
+
+	exclude, _ := options.GetString("exclude")
+	for _, raw := range strings.Split(exclude, ",") {
+		if raw = strings.TrimSpace(raw); raw == "" {
+			continue
+		}
+		p, err := ParseNamespacePattern(raw)
+		if err != nil {
+			return nil, err
+		}
+		scope.Exclude = append(scope.Exclude, p)
+	}
+
+	scope.NamespaceSelector, _ = options.GetString("namespace-selector")
+	scope.LabelSelector, _ = options.GetString("selector")
+	scope.FieldSelector, _ = options.GetString("field-selector")
+	scope.PageSize, _ = options.GetInt64("page-size")
+	if scope.PageSize < 0 {
+		return nil, fmt.Errorf("invalid --page-size: %d", scope.PageSize)
+	}
+	if _, err := labels.Parse(scope.NamespaceSelector); err != nil {
+		return nil, fmt.Errorf("invalid --namespace-selector: %w", err)
+	}
+	if _, err := labels.Parse(scope.LabelSelector); err != nil {
+		return nil, fmt.Errorf("invalid --selector: %w", err)
+	}
+	if _, err := fields.ParseSelector(scope.FieldSelector); err != nil {
+		return nil, fmt.Errorf("invalid --field-selector: %w", err)
+	}
+	return scope, nil
+}
+
+// ListNamespace 返回可以直接交给 API Server 的命名空间：只包含一个精确命名空间时返回它，否则返回空字符串(所有命名空间)
+func (s *Scope) ListNamespace() string {
+	if len(s.Include) == 1 && s.Include[0].exact() {
+		return s.Include[0].raw
+	}
+	return ""
+}
+
+// ListOptions 返回带有 Pod 标签和字段选择器的 List 选项
+func (s *Scope) ListOptions() metav1.ListOptions {
+	return metav1.ListOptions{LabelSelector: s.LabelSelector, FieldSelector: s.FieldSelector}
+}
+
+// NamespaceMatcher 返回判断命名空间是否在扫描范围内的函数。设置了命名空间标签选择器时，
+// 会先从集群中查询匹配的命名空间，之后新建的命名空间不会被包含
+func (s *Scope) NamespaceMatcher(ctx context.Context, clientset kubernetes.Interface) (func(namespace string) bool, error) {
+	var selected map[string]bool
+	if s.NamespaceSelector != "" {
+		list, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: s.NamespaceSelector})
+		if err != nil {
+			return nil, fmt.Errorf("按标签 %q 查询命名空间失败: %w", s.NamespaceSelector, err)
+		}
+		selected = make(map[string]bool, len(list.Items))
+		for _, ns := range list.Items {
+			selected[ns.Name] = true
+		}
+	}
+	return func(namespace string) bool {
+		return s.matchNamespace(namespace, selected)
+	}, nil
+}
+
+func (s *Scope) matchNamespace(namespace string, selected map[string]bool) bool {
+	if selected != nil && !selected[namespace] {
+		return false
+	}
+	for _, p := range s.Exclude {
+		if p.Match(namespace) {
+			return false
+		}
+	}
+	if len(s.Include) == 0 {
+		return true
+	}
+	for _, p := range s.Include {
+		if p.Match(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchLabels 判断一组标签是否满足 Pod 标签选择器，用于无法在服务端过滤的 Pod 模板
+func (s *Scope) MatchLabels(podLabels map[string]string) bool {
+	selector, err := labels.Parse(s.LabelSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(podLabels))
+}
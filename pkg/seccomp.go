@@ -0,0 +1,149 @@
+package pkg
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// 已废弃的 seccomp 注解，Kubernetes 1.19 之前通过它们设置 seccomp profile
+const (
+	seccompPodAnnotation             = "seccomp.security.alpha.kubernetes.io/pod"
+	seccompContainerAnnotationPrefix = "container.seccomp.security.alpha.kubernetes.io/"
+)
+
+// 生效的 seccomp profile 的来源，按优先级从高到低排列
+const (
+	SeccompSourceContainer           = "container"
+	SeccompSourceContainerAnnotation = "container annotation"
+	SeccompSourcePod                 = "pod"
+	SeccompSourcePodAnnotation       = "pod annotation"
+)
+
+// seccompProfile 是容器生效的 seccomp profile 和它的来源
+type seccompProfile struct {
+	Type             corev1.SeccompProfileType
+	LocalhostProfile string
+	Source           string
+}
+
+// valid 判断 profile 是否为 RuntimeDefault 或指定了 localhostProfile 的 Localhost
+func (p *seccompProfile) valid() bool {
+	switch p.Type {
+	case corev1.SeccompProfileTypeRuntimeDefault:
+		return true
+	case corev1.SeccompProfileTypeLocalhost:
+		return p.LocalhostProfile != ""
+	}
+	return false
+}
+
+// String 返回 profile 和来源，例如 Unconfined (pod annotation)
+func (p *seccompProfile) String() string {
+	profile := string(p.Type)
+	if p.Type == corev1.SeccompProfileTypeLocalhost {
+		if p.LocalhostProfile == "" {
+			profile += " without localhostProfile"
+		} else {
+			profile += " " + p.LocalhostProfile
+		}
+	}
+	return profile + " (" + p.Source + ")"
+}
+
+func fieldSeccomp(profile *corev1.SeccompProfile, source string) *seccompProfile {
+	if profile == nil {
+		return nil
+	}
+	p := &seccompProfile{Type: profile.Type, Source: source}
+	if profile.LocalhostProfile != nil {
+		p.LocalhostProfile = *profile.LocalhostProfile
+	}
+	return p
+}
+
+// annotationSeccomp 把注解的值转换为 profile：runtime/default 和 docker/default 对应 RuntimeDefault，
+// localhost/<path> 对应 Localhost，其他无法识别的值原样保留
+func annotationSeccomp(annotations map[string]string, key, source string) *seccompProfile {
+	value, ok := annotations[key]
+	if !ok {
+		return nil
+	}
+	p := &seccompProfile{Source: source}
+	switch {
+	case value == "runtime/default" || value == "docker/default":
+		p.Type = corev1.SeccompProfileTypeRuntimeDefault
+	case value == "unconfined":
+		p.Type = corev1.SeccompProfileTypeUnconfined
+	case strings.HasPrefix(value, "localhost/"):
+		p.Type = corev1.SeccompProfileTypeLocalhost
+		p.LocalhostProfile = strings.TrimPrefix(value, "localhost/")
+	default:
+		p.Type = corev1.SeccompProfileType(value)
+	}
+	return p
+}
+
+// podSeccomp 返回 Pod 级别的 profile，字段优先于注解，都没有设置时返回 nil
+func podSeccomp(pod *corev1.Pod) *seccompProfile {
+	if psc := pod.Spec.SecurityContext; psc != nil && psc.SeccompProfile != nil {
+		return fieldSeccomp(psc.SeccompProfile, SeccompSourcePod)
+	}
+	return annotationSeccomp(pod.Annotations, seccompPodAnnotation, SeccompSourcePodAnnotation)
+}
+
+// effectiveSeccomp 返回容器生效的 profile：容器的字段、容器的注解、Pod 的字段、Pod 的注解，
+// 都没有设置时返回 nil，由容器运行时决定(通常是 Unconfined)
+func effectiveSeccomp(pod *corev1.Pod, container *corev1.Container) *seccompProfile {
+	if sc := container.SecurityContext; sc != nil && sc.SeccompProfile != nil {
+		return fieldSeccomp(sc.SeccompProfile, SeccompSourceContainer)
+	}
+	if p := annotationSeccomp(pod.Annotations, seccompContainerAnnotationPrefix+container.Name, SeccompSourceContainerAnnotation); p != nil {
+		return p
+	}
+	return podSeccomp(pod)
+}
+
+// seccompFindings 对每个容器生效的 profile 调用 violates，违规时报告 profile 和来源。
+// 所有容器都覆盖了 Pod 级别的无效 profile 时，Pod 级别的设置仍然违反标准，单独报告在 Pod 上
+func seccompFindings(pod *corev1.Pod, violates func(p *seccompProfile) bool) []Finding {
+	var findings []Finding
+	podLevel := podSeccomp(pod)
+	inherited := false
+	visitContainers(&pod.Spec, func(container *corev1.Container, containerType string) {
+		p := effectiveSeccomp(pod, container)
+		if !violates(p) {
+			return
+		}
+		f := containerFinding(pod, container, containerType)
+		f.Value = "unset"
+		if p != nil {
+			f.Value = p.String()
+			inherited = inherited || p.Source == SeccompSourcePod || p.Source == SeccompSourcePodAnnotation
+		}
+		findings = append(findings, f)
+	})
+	if podLevel != nil && violates(podLevel) && !inherited {
+		f := podFinding(pod)
+		f.Value = podLevel.String()
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// Seccomp 报告生效的 profile 被显式设置为 Unconfined 或其他无效值的容器，Baseline 允许不设置 profile
+func Seccomp(pod *corev1.Pod) []Finding {
+	return seccompFindings(pod, func(p *seccompProfile) bool {
+		return p != nil && !p.valid()
+	})
+}
+
+// SeccompProfile 报告生效的 profile 不是 RuntimeDefault 或 Localhost 的容器，包括没有设置 profile 的容器
+func SeccompProfile(pod *corev1.Pod, version PSSVersion) []Finding {
+	if windowsExempt(pod, version) {
+		return nil
+	}
+	return seccompFindings(pod, func(p *seccompProfile) bool {
+		return p == nil || !p.valid()
+	})
+}
@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func seccompType(t corev1.SeccompProfileType) *corev1.SeccompProfile {
+	return &corev1.SeccompProfile{Type: t}
+}
+
+func localhostSeccomp(profile string) *corev1.SeccompProfile {
+	return &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: &profile}
+}
+
+// seccompPod 返回包含 app 和 sidecar 两个容器的 Pod，app 使用 container 中的 profile
+func seccompPod(annotations map[string]string, pod, container *corev1.SeccompProfile) *corev1.Pod {
+	p := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}, {Name: "sidecar"}}}}
+	p.Annotations = annotations
+	if pod != nil {
+		p.Spec.SecurityContext = &corev1.PodSecurityContext{SeccompProfile: pod}
+	}
+	if container != nil {
+		p.Spec.Containers[0].SecurityContext = &corev1.SecurityContext{SeccompProfile: container}
+	}
+	return p
+}
+
+func TestAnnotationSeccomp(t *testing.T) {
+	tests := []struct {
+		value string
+		want  seccompProfile
+		valid bool
+	}{
+		{"runtime/default", seccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}, true},
+		{"docker/default", seccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}, true},
+		{"unconfined", seccompProfile{Type: corev1.SeccompProfileTypeUnconfined}, false},
+		{"localhost/profiles/audit.json", seccompProfile{Type: corev1.SeccompProfileTypeLocalhost, LocalhostProfile: "profiles/audit.json"}, true},
+		{"localhost/", seccompProfile{Type: corev1.SeccompProfileTypeLocalhost}, false},
+		{"something-else", seccompProfile{Type: "something-else"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			tt.want.Source = SeccompSourcePodAnnotation
+			got := annotationSeccomp(map[string]string{seccompPodAnnotation: tt.value}, seccompPodAnnotation, SeccompSourcePodAnnotation)
+			if got == nil || *got != tt.want {
+				t.Fatalf("annotationSeccomp(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+			if got.valid() != tt.valid {
+				t.Errorf("valid() = %v, want %v", got.valid(), tt.valid)
+			}
+		})
+	}
+	if got := annotationSeccomp(nil, seccompPodAnnotation, SeccompSourcePodAnnotation); got != nil {
+		t.Errorf("annotationSeccomp without annotation = %+v, want nil", got)
+	}
+}
+
+func TestEffectiveSeccomp(t *testing.T) {
+	containerAnnotation := seccompContainerAnnotationPrefix + "app"
+	tests := []struct {
+		name string
+		pod  *corev1.Pod
+		want string // 空字符串表示没有设置 profile
+	}{
+		{name: "unset", pod: seccompPod(nil, nil, nil)},
+		{name: "pod annotation", pod: seccompPod(map[string]string{seccompPodAnnotation: "unconfined"}, nil, nil),
+			want: "Unconfined (pod annotation)"},
+		{name: "pod field over pod annotation",
+			pod:  seccompPod(map[string]string{seccompPodAnnotation: "unconfined"}, seccompType(corev1.SeccompProfileTypeRuntimeDefault), nil),
+			want: "RuntimeDefault (pod)"},
+		{name: "container annotation over pod field",
+			pod:  seccompPod(map[string]string{containerAnnotation: "localhost/audit.json"}, seccompType(corev1.SeccompProfileTypeUnconfined), nil),
+			want: "Localhost audit.json (container annotation)"},
+		{name: "container field over container annotation",
+			pod:  seccompPod(map[string]string{containerAnnotation: "runtime/default"}, nil, seccompType(corev1.SeccompProfileTypeUnconfined)),
+			want: "Unconfined (container)"},
+		{name: "container field over everything",
+			pod: seccompPod(map[string]string{seccompPodAnnotation: "unconfined", containerAnnotation: "unconfined"},
+				seccompType(corev1.SeccompProfileTypeUnconfined), localhostSeccomp("audit.json")),
+			want: "Localhost audit.json (container)"},
+		{name: "annotation for another container",
+			pod:  seccompPod(map[string]string{seccompContainerAnnotationPrefix + "sidecar": "unconfined"}, nil, nil),
+			want: ""},
+		{name: "localhost field without profile",
+			pod:  seccompPod(nil, nil, seccompType(corev1.SeccompProfileTypeLocalhost)),
+			want: "Localhost without localhostProfile (container)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := effectiveSeccomp(tt.pod, &tt.pod.Spec.Containers[0])
+			got := ""
+			if p != nil {
+				got = p.String()
+			}
+			if got != tt.want {
+				t.Errorf("effectiveSeccomp = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Seccomp 只报告显式设置的无效 profile，SeccompProfile 还报告没有设置 profile 的容器
+func TestSeccompSplit(t *testing.T) {
+	runtimeDefault := seccompType(corev1.SeccompProfileTypeRuntimeDefault)
+	unconfined := seccompType(corev1.SeccompProfileTypeUnconfined)
+	tests := []struct {
+		name       string
+		pod        *corev1.Pod
+		baseline   []fieldResult
+		restricted []fieldResult
+	}{
+		{name: "unset", pod: seccompPod(nil, nil, nil), restricted: []fieldResult{
+			{container: "container/app", value: "unset"},
+			{container: "container/sidecar", value: "unset"},
+		}},
+		{name: "runtime default on pod", pod: seccompPod(nil, runtimeDefault, nil)},
+		{name: "localhost on pod", pod: seccompPod(nil, localhostSeccomp("audit.json"), nil)},
+		{name: "localhost without profile", pod: seccompPod(nil, seccompType(corev1.SeccompProfileTypeLocalhost), nil),
+			baseline: []fieldResult{
+				{container: "container/app", value: "Localhost without localhostProfile (pod)"},
+				{container: "container/sidecar", value: "Localhost without localhostProfile (pod)"},
+			},
+			restricted: []fieldResult{
+				{container: "container/app", value: "Localhost without localhostProfile (pod)"},
+				{container: "container/sidecar", value: "Localhost without localhostProfile (pod)"},
+			}},
+		{name: "unconfined container", pod: seccompPod(nil, runtimeDefault, unconfined),
+			baseline:   []fieldResult{{container: "container/app", value: "Unconfined (container)"}},
+			restricted: []fieldResult{{container: "container/app", value: "Unconfined (container)"}}},
+		{name: "unconfined container annotation", pod: seccompPod(map[string]string{seccompContainerAnnotationPrefix + "sidecar": "unconfined"}, nil, runtimeDefault),
+			baseline:   []fieldResult{{container: "container/sidecar", value: "Unconfined (container annotation)"}},
+			restricted: []fieldResult{{container: "container/sidecar", value: "Unconfined (container annotation)"}}},
+		{name: "unconfined pod annotation with one container set", pod: seccompPod(map[string]string{seccompPodAnnotation: "unconfined"}, nil, runtimeDefault),
+			baseline:   []fieldResult{{container: "container/sidecar", value: "Unconfined (pod annotation)"}},
+			restricted: []fieldResult{{container: "container/sidecar", value: "Unconfined (pod annotation)"}}},
+		// 所有容器都覆盖了 Pod 的无效 profile，Pod 级别的设置单独报告
+		{name: "unconfined pod overridden everywhere", pod: func() *corev1.Pod {
+			pod := seccompPod(nil, unconfined, runtimeDefault)
+			pod.Spec.Containers[1].SecurityContext = &corev1.SecurityContext{SeccompProfile: runtimeDefault}
+			return pod
+		}(),
+			baseline:   []fieldResult{{value: "Unconfined (pod)"}},
+			restricted: []fieldResult{{value: "Unconfined (pod)"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fieldResults(Seccomp(tt.pod)); !reflect.DeepEqual(got, tt.baseline) {
+				t.Errorf("Seccomp = %+v, want %+v", got, tt.baseline)
+			}
+			if got := fieldResults(SeccompProfile(tt.pod, PSSVersion{})); !reflect.DeepEqual(got, tt.restricted) {
+				t.Errorf("SeccompProfile = %+v, want %+v", got, tt.restricted)
+			}
+		})
+	}
+
+	windows := seccompPod(nil, nil, nil)
+	windows.Spec.OS = &corev1.PodOS{Name: corev1.Windows}
+	if got := SeccompProfile(windows, PSSVersion{}); got != nil {
+		t.Errorf("SeccompProfile for a Windows pod = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 抑制规则的来源
+const (
+	SuppressionSourceFile       = "file"       // --suppressions 指定的规则文件
+	SuppressionSourceAnnotation = "annotation" // Pod 的 getnopss.io/exempt 注解，见 podExemptions
+)
+
+// suppressionDateLayout 是 expires 字段的日期格式，规则在该日期当天结束前有效
+const suppressionDateLayout = "2006-01-02"
+
+// Suppression 是一条抑制规则，匹配的问题不会出现在报告的主列表中。
+// Namespace、Pod、Container 和 Path 支持 glob，空值匹配任意值；Pod 同时匹配 Pod 名称和顶层控制器名称
+type Suppression struct {
+	Check     string `json:"check" yaml:"check"` // 检查 ID，* 表示所有检查
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty" yaml:"pod,omitempty"`
+	Container string `json:"container,omitempty" yaml:"container,omitempty"`
+	Path      string `json:"path,omitempty" yaml:"path,omitempty"`
+	Reason    string `json:"reason" yaml:"reason"`
+	Owner     string `json:"owner" yaml:"owner"`
+	Expires   string `json:"expires,omitempty" yaml:"expires,omitempty"` // 过期日期，格式为 2006-01-02
+	Source    string `json:"source" yaml:"-"`                            // 规则来源，见 SuppressionSource* 常量
+	Expired   bool   `json:"expired,omitempty" yaml:"-"`                 // 规则已过期，匹配的问题仍然有效
+	expires   time.Time
+}
+
+// SuppressionFile 是抑制规则文件的结构
+type SuppressionFile struct {
+	Suppressions []Suppression `yaml:"suppressions"`
+}
+
+// LoadSuppressions 读取并校验抑制规则文件，每条规则必须有 check、reason 和 owner
+func LoadSuppressions(file string) ([]Suppression, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read suppressions file %s: %w", file, err)
+	}
+	var sf SuppressionFile
+	if err := yaml.Unmarshal(data, &sf); err != nil {
+		return nil, fmt.Errorf("failed to parse suppressions file %s: %w", file, err)
+	}
+	for i := range sf.Suppressions {
+		s := &sf.Suppressions[i]
+		s.Source = SuppressionSourceFile
+		if err := s.validate(); err != nil {
+			return nil, fmt.Errorf("%s: suppression %d: %w", file, i+1, err)
+		}
+	}
+	return sf.Suppressions, nil
+}
+
+func (s *Suppression) validate() error {
+	if s.Check == "" {
+		return fmt.Errorf("check is required")
+	}
+	if s.Check != "*" {
+		if _, ok := LookupCheck(s.Check); !ok {
+			return fmt.Errorf("unknown check %q", s.Check)
+		}
+	}
+	if s.Reason == "" {
+		return fmt.Errorf("reason is required")
+	}
+	if s.Owner == "" {
+		return fmt.Errorf("owner is required")
+	}
+	for _, pattern := range []string{s.Namespace, s.Pod, s.Container, s.Path} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	if s.Expires != "" {
+		expires, err := time.Parse(suppressionDateLayout, s.Expires)
+		if err != nil {
+			return fmt.Errorf("invalid expires %q, expected YYYY-MM-DD", s.Expires)
+		}
+		s.expires = expires.AddDate(0, 0, 1)
+	}
+	return nil
+}
+
+// Suppressed 判断问题是否被有效的抑制规则匹配
+func (f Finding) Suppressed() bool {
+	return f.Suppression != nil && !f.Suppression.Expired
+}
+
+// Match 判断规则是否匹配问题
+func (s *Suppression) Match(f Finding) bool {
+	if s.Check != "*" && s.Check != f.Check {
+		return false
+	}
+	if !globMatch(s.Namespace, f.Namespace) || !globMatch(s.Container, f.Container) || !globMatch(s.Path, f.Path) {
+		return false
+	}
+	return s.Pod == "" || globMatch(s.Pod, f.Pod) || (f.OwnerName != "" && globMatch(s.Pod, f.OwnerName))
+}
+
+// String 返回规则的匹配条件，用于报告
+func (s *Suppression) String() string {
+	desc := "check " + s.Check
+	for _, field := range []struct{ name, value string }{
+		{"namespace", s.Namespace}, {"pod", s.Pod}, {"container", s.Container}, {"path", s.Path},
+	} {
+		if field.value != "" {
+			desc += " : " + field.name + " " + field.value
+		}
+	}
+	return desc
+}
+
+func globMatch(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+// ApplySuppressions 把匹配抑制规则的问题，以及检查时已经通过注解豁免的问题，从 result.Findings 移到 result.Suppressed。
+// 过期规则不再生效，匹配的问题保留在主列表中并记录该规则，过期规则记录在 result.ExpiredSuppressions 中
+func ApplySuppressions(result *ScanResult, suppressions []Suppression, now time.Time) {
+	rules := make([]Suppression, len(suppressions))
+	copy(rules, suppressions)
+	for i := range rules {
+		if !rules[i].expires.IsZero() && !now.Before(rules[i].expires) {
+			rules[i].Expired = true
+			result.ExpiredSuppressions = append(result.ExpiredSuppressions, rules[i])
+		}
+	}
+
+	active := result.Findings[:0]
+	for _, f := range result.Findings {
+		if f.Suppressed() {
+			result.Suppressed = append(result.Suppressed, f)
+			continue
+		}
+		var expired *Suppression
+		suppressed := false
+		for i := range rules {
+			if !rules[i].Match(f) {
+				continue
+			}
+			if rules[i].Expired {
+				if expired == nil {
+					expired = &rules[i]
+				}
+				continue
+			}
+			f.Suppression = &rules[i]
+			suppressed = true
+			break
+		}
+		if suppressed {
+			result.Suppressed = append(result.Suppressed, f)
+			continue
+		}
+		f.Suppression = expired
+		active = append(active, f)
+	}
+	result.Findings = active
+}
@@ -0,0 +1,231 @@
+package pkg
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSuppressionValidate(t *testing.T) {
+	valid := Suppression{Check: "hostpath", Reason: "node metrics", Owner: "platform-team"}
+	tests := []struct {
+		name    string
+		mutate  func(s *Suppression)
+		wantErr string
+	}{
+		{name: "valid", mutate: func(s *Suppression) {}},
+		{name: "all checks", mutate: func(s *Suppression) { s.Check = "*" }},
+		{name: "patterns", mutate: func(s *Suppression) { s.Namespace, s.Pod, s.Path = "kube-*", "node-exporter*", "/proc/[a-z]*" }},
+		{name: "expires", mutate: func(s *Suppression) { s.Expires = "2025-12-31" }},
+		{name: "missing check", mutate: func(s *Suppression) { s.Check = "" }, wantErr: "check is required"},
+		{name: "unknown check", mutate: func(s *Suppression) { s.Check = "hostnetwork" }, wantErr: `unknown check "hostnetwork"`},
+		{name: "missing reason", mutate: func(s *Suppression) { s.Reason = "" }, wantErr: "reason is required"},
+		{name: "missing owner", mutate: func(s *Suppression) { s.Owner = "" }, wantErr: "owner is required"},
+		{name: "bad pattern", mutate: func(s *Suppression) { s.Container = "app[" }, wantErr: `invalid pattern "app["`},
+		{name: "bad date", mutate: func(s *Suppression) { s.Expires = "31/12/2025" }, wantErr: `invalid expires "31/12/2025"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid
+			tt.mutate(&s)
+			err := s.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("validate() = %v, want nil", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSuppressions(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "suppressions.yaml")
+	data := `suppressions:
+  - check: hostpath
+    namespace: monitoring
+    reason: node metrics
+    owner: platform-team
+  - check: hostpid
+    reason: debugging
+`
+	if err := os.WriteFile(file, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadSuppressions(file)
+	if err == nil || !strings.Contains(err.Error(), "suppression 2: owner is required") {
+		t.Errorf("LoadSuppressions error = %v, want the second rule to be rejected", err)
+	}
+}
+
+// expires 当天仍然有效，次日零点(UTC)起过期
+func TestSuppressionExpiresInclusive(t *testing.T) {
+	rule := Suppression{Check: "hostpath", Reason: "node metrics", Owner: "platform-team", Expires: "2025-12-31"}
+	if err := rule.validate(); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		now     time.Time
+		expired bool
+	}{
+		{now: time.Date(2025, 12, 30, 12, 0, 0, 0, time.UTC), expired: false},
+		{now: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), expired: false},
+		{now: time.Date(2025, 12, 31, 23, 59, 59, 0, time.UTC), expired: false},
+		{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), expired: true},
+		{now: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), expired: true},
+	}
+	for _, tt := range tests {
+		result := ScanResult{Findings: []Finding{{Check: "hostpath", Namespace: "monitoring", Pod: "node-exporter-4xz2q"}}}
+		ApplySuppressions(&result, []Suppression{rule}, tt.now)
+		if got := len(result.ExpiredSuppressions) == 1; got != tt.expired {
+			t.Errorf("at %s: expired = %v, want %v", tt.now, got, tt.expired)
+		}
+		if got := len(result.Suppressed) == 0; got != tt.expired {
+			t.Errorf("at %s: finding suppressed = %v, want %v", tt.now, !got, !tt.expired)
+		}
+	}
+}
+
+func TestSuppressionMatch(t *testing.T) {
+	finding := Finding{
+		Check: "hostpath", Namespace: "monitoring", Pod: "node-exporter-4xz2q",
+		OwnerKind: "DaemonSet", OwnerName: "node-exporter", Container: "exporter", Path: "/proc",
+	}
+	tests := []struct {
+		name string
+		rule Suppression
+		want bool
+	}{
+		{name: "check only", rule: Suppression{Check: "hostpath"}, want: true},
+		{name: "all checks", rule: Suppression{Check: "*"}, want: true},
+		{name: "other check", rule: Suppression{Check: "hostpid"}, want: false},
+		{name: "namespace glob", rule: Suppression{Check: "hostpath", Namespace: "monitor*"}, want: true},
+		{name: "other namespace", rule: Suppression{Check: "hostpath", Namespace: "kube-system"}, want: false},
+		{name: "pod name", rule: Suppression{Check: "hostpath", Pod: "node-exporter-4xz2q"}, want: true},
+		{name: "owner name", rule: Suppression{Check: "hostpath", Pod: "node-exporter"}, want: true},
+		{name: "pod glob", rule: Suppression{Check: "hostpath", Pod: "node-*"}, want: true},
+		{name: "other pod", rule: Suppression{Check: "hostpath", Pod: "web*"}, want: false},
+		{name: "container", rule: Suppression{Check: "hostpath", Container: "exporter"}, want: true},
+		{name: "other container", rule: Suppression{Check: "hostpath", Container: "sidecar"}, want: false},
+		{name: "path glob", rule: Suppression{Check: "hostpath", Path: "/pr*"}, want: true},
+		{name: "glob does not cross /", rule: Suppression{Check: "hostpath", Path: "/*/x"}, want: false},
+		{name: "all fields", rule: Suppression{Check: "hostpath", Namespace: "monitoring", Pod: "node-exporter", Container: "exp*", Path: "/proc"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.Match(finding); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// 没有控制器的 Pod 不会按空的控制器名称匹配
+	standalone := Finding{Check: "hostpath", Pod: "debug"}
+	if (&Suppression{Check: "hostpath", Pod: "node-*"}).Match(standalone) {
+		t.Error("rule for node-* matched standalone pod debug")
+	}
+}
+
+func TestApplySuppressions(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	rule := func(check, pod, expires string) Suppression {
+		s := Suppression{Check: check, Pod: pod, Reason: "reason", Owner: "owner", Expires: expires, Source: SuppressionSourceFile}
+		if err := s.validate(); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	rules := []Suppression{
+		rule("hostpath", "node-exporter", "2025-05-31"), // 已过期
+		rule("hostpath", "node-*", ""),
+		rule("hostpid", "agent", "2025-01-01"), // 已过期，没有其他规则匹配
+	}
+	exempted := &Suppression{Check: "privileged", Pod: "csi", Reason: "driver", Source: SuppressionSourceAnnotation}
+	result := ScanResult{Findings: []Finding{
+		{Check: "hostpath", Pod: "node-exporter-4xz2q", OwnerName: "node-exporter"},
+		{Check: "hostpid", Pod: "agent"},
+		{Check: "privileged", Pod: "csi", Suppression: exempted},
+		{Check: "hostnet", Pod: "web"},
+	}}
+	ApplySuppressions(&result, rules, now)
+
+	type entry struct {
+		check string
+		rule  *Suppression
+	}
+	entries := func(findings []Finding) []entry {
+		var got []entry
+		for _, f := range findings {
+			got = append(got, entry{f.Check, f.Suppression})
+		}
+		return got
+	}
+
+	// 过期规则之后的有效规则仍然抑制问题
+	if got, want := entries(result.Suppressed), []entry{{"hostpath", &rules[1]}, {"privileged", exempted}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("suppressed = %+v, want %+v", got, want)
+	}
+	// 只匹配过期规则的问题留在主列表中并记录该规则
+	if len(result.Findings) != 2 || result.Findings[0].Check != "hostpid" || result.Findings[1].Check != "hostnet" {
+		t.Fatalf("findings = %+v, want hostpid and hostnet", entries(result.Findings))
+	}
+	if s := result.Findings[0].Suppression; s == nil || !s.Expired || s.Pod != "agent" {
+		t.Errorf("hostpid suppression = %+v, want the expired agent rule", s)
+	}
+	if result.Findings[0].Suppressed() {
+		t.Error("finding with an expired rule reported as suppressed")
+	}
+	if result.Findings[1].Suppression != nil {
+		t.Errorf("hostnet suppression = %+v, want nil", result.Findings[1].Suppression)
+	}
+	if len(result.ExpiredSuppressions) != 2 || result.ExpiredSuppressions[0].Pod != "node-exporter" || result.ExpiredSuppressions[1].Pod != "agent" {
+		t.Errorf("expired suppressions = %+v, want the node-exporter and agent rules", result.ExpiredSuppressions)
+	}
+	// 调用方传入的规则不会被修改
+	if rules[0].Expired || rules[2].Expired {
+		t.Error("ApplySuppressions modified the caller's rules")
+	}
+}
+
+func TestPodExemptionsUnknownCheck(t *testing.T) {
+	var logs bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&logs)
+	defer func() { log.Logger = logger }()
+
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "monitoring",
+		Name:      "node-exporter",
+		Annotations: map[string]string{
+			ExemptAnnotation:       "hostpath, hostnetwork,,hostpid",
+			ExemptReasonAnnotation: " node metrics ",
+		},
+	}}
+	exemptions := podExemptions(pod)
+	var ids []string
+	for id, s := range exemptions {
+		ids = append(ids, id)
+		if s.Reason != "node metrics" || s.Source != SuppressionSourceAnnotation || s.Pod != "node-exporter" {
+			t.Errorf("exemption %s = %+v", id, s)
+		}
+	}
+	if len(ids) != 2 || exemptions["hostpath"] == nil || exemptions["hostpid"] == nil {
+		t.Errorf("exempted checks = %v, want hostpath and hostpid", ids)
+	}
+	if !strings.Contains(logs.String(), `"warn"`) || !strings.Contains(logs.String(), "hostnetwork") {
+		t.Errorf("no warning for unknown check hostnetwork, logs: %s", logs.String())
+	}
+
+	if got := exemptionFor(podExemptions(testPod("default", map[string]string{ExemptAnnotation: "*"}, nil)), "privileged"); got == nil {
+		t.Error("* did not exempt privileged")
+	}
+}
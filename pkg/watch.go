@@ -0,0 +1,223 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	FindingEventNew      = "NEW"
+	FindingEventResolved = "RESOLVED"
+)
+
+// FindingEvent 表示 watch 模式中一个问题的出现或消失
+type FindingEvent struct {
+	Type    string
+	Finding Finding
+}
+
+type trackedFinding struct {
+	finding Finding
+	pods    int
+}
+
+// FindingTracker 维护集群当前的问题集合，只在问题第一次出现或最后一次消失时产生事件。
+// 同一工作负载的多个 Pod 上相同的问题只计为一个，Pod 重建和 informer 重新同步不会产生重复事件
+type FindingTracker struct {
+	mu       sync.Mutex
+	checks   []Check
+	resolve  func(pod *corev1.Pod) Owner
+	pods     map[string][]string // Pod → 该 Pod 当前贡献的问题键
+	findings map[string]*trackedFinding
+}
+
+// NewFindingTracker 创建问题跟踪器，resolve 用于把 Pod 归属到顶层控制器，可以为 nil
+func NewFindingTracker(checks []Check, resolve func(pod *corev1.Pod) Owner) *FindingTracker {
+	return &FindingTracker{
+		checks:   checks,
+		resolve:  resolve,
+		pods:     make(map[string][]string),
+		findings: make(map[string]*trackedFinding),
+	}
+}
+
+// Update 重新检查一个新增或变更的 Pod，返回因此出现或消失的问题
+func (t *FindingTracker) Update(pod *corev1.Pod) []FindingEvent {
+	podFindings := EvaluatePod(pod, t.checks)
+	if len(podFindings) > 0 && t.resolve != nil {
+		owner := t.resolve(pod)
+		for i := range podFindings {
+			podFindings[i].OwnerKind = owner.Kind
+			podFindings[i].OwnerName = owner.Name
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var events []FindingEvent
+	keys := make([]string, 0, len(podFindings))
+	for _, f := range podFindings {
+		key := trackerKey(f)
+		keys = append(keys, key)
+		// 先登记新的问题再释放旧的，同一问题在更新前后都存在时计数不会归零
+		if tf, ok := t.findings[key]; ok {
+			tf.pods++
+		} else {
+			t.findings[key] = &trackedFinding{finding: f, pods: 1}
+			events = append(events, FindingEvent{Type: FindingEventNew, Finding: f})
+		}
+	}
+	events = append(events, t.release(podKey(pod.Namespace, pod.Name))...)
+	t.pods[podKey(pod.Namespace, pod.Name)] = keys
+	return events
+}
+
+// Delete 移除一个已删除的 Pod，返回因此消失的问题
+func (t *FindingTracker) Delete(namespace, name string) []FindingEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.release(podKey(namespace, name))
+}
+
+// release 释放 Pod 之前贡献的问题，返回计数归零的问题
+func (t *FindingTracker) release(pod string) []FindingEvent {
+	var events []FindingEvent
+	for _, key := range t.pods[pod] {
+		tf, ok := t.findings[key]
+		if !ok {
+			continue
+		}
+		tf.pods--
+		if tf.pods == 0 {
+			delete(t.findings, key)
+			events = append(events, FindingEvent{Type: FindingEventResolved, Finding: tf.finding})
+		}
+	}
+	delete(t.pods, pod)
+	return events
+}
+
+// Current 返回当前存在的所有问题，Replicas 为出现该问题的 Pod 数量
+func (t *FindingTracker) Current() []Finding {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	keys := make([]string, 0, len(t.findings))
+	for key := range t.findings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	findings := make([]Finding, 0, len(keys))
+	for _, key := range keys {
+		tf := t.findings[key]
+		f := tf.finding
+		if f.OwnerKind != "" {
+			f.Replicas = tf.pods
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
+
+// PodCount 返回当前跟踪的 Pod 数量
+func (t *FindingTracker) PodCount() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pods)
+}
+
+func podKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// trackerKey 有控制器的问题按工作负载计数，否则按 Pod 计数
+func trackerKey(f Finding) string {
+	if f.OwnerKind != "" {
+		return f.OwnerKind + "/" + f.OwnerName + "|" + f.contentKey()
+	}
+	return "Pod/" + f.Pod + "|" + f.contentKey()
+}
+
+// WatchHandlers 是 watch 模式的回调
+type WatchHandlers struct {
+	// OnSynced 在首次同步完成后调用一次，参数为此时集群中的全部问题
+	OnSynced func(current []Finding)
+	// OnEvents 在首次同步完成后，每当问题出现或消失时调用
+	OnEvents func(events []FindingEvent)
+}
+
+// WatchPods 使用 SharedInformer 持续监听 Pod 的变化并交给 tracker 评估，直到 ctx 结束。
+// 首次同步期间的变化只更新状态，不触发 OnEvents
+func WatchPods(ctx context.Context, clientset kubernetes.Interface, scope *Scope, resync time.Duration, tracker *FindingTracker, handlers WatchHandlers) error {
+	inScope, err := scope.NamespaceMatcher(ctx, clientset)
+	if err != nil {
+		return err
+	}
+	var synced atomic.Bool
+	emit := func(events []FindingEvent) {
+		if len(events) > 0 && synced.Load() && handlers.OnEvents != nil {
+			handlers.OnEvents(events)
+		}
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, resync,
+		informers.WithNamespace(scope.ListNamespace()),
+		informers.WithTweakListOptions(func(o *metav1.ListOptions) {
+			o.LabelSelector = scope.LabelSelector
+			o.FieldSelector = scope.FieldSelector
+		}))
+	informer := factory.Core().V1().Pods().Informer()
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			if pod, ok := obj.(*corev1.Pod); ok && inScope(pod.Namespace) {
+				emit(tracker.Update(pod))
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			oldPod, _ := oldObj.(*corev1.Pod)
+			pod, ok := newObj.(*corev1.Pod)
+			if !ok || !inScope(pod.Namespace) {
+				return
+			}
+			// 定期 resync 时对象没有变化，无需重新检查
+			if oldPod != nil && oldPod.ResourceVersion == pod.ResourceVersion {
+				return
+			}
+			emit(tracker.Update(pod))
+		},
+		DeleteFunc: func(obj interface{}) {
+			// 重新 list 时发现已消失的 Pod 会以 DeletedFinalStateUnknown 的形式传入
+			if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if pod, ok := obj.(*corev1.Pod); ok && inScope(pod.Namespace) {
+				emit(tracker.Delete(pod.Namespace, pod.Name))
+			}
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("等待 Pod informer 同步失败")
+	}
+	synced.Store(true)
+	if handlers.OnSynced != nil {
+		handlers.OnSynced(tracker.Current())
+	}
+
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,197 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxAdmissionBody 限制 AdmissionReview 请求体的大小，与 API Server 的请求上限一致
+const maxAdmissionBody = 3 * 1024 * 1024
+
+// AdmissionPolicy 决定准入请求中发现问题时的处理方式
+type AdmissionPolicy struct {
+	Checks            []Check
+	DenySeverity      Severity            // 达到该严重程度的问题会拒绝请求，空值表示从不拒绝
+	WarnSeverity      Severity            // 达到该严重程度的问题作为警告返回，空值表示不返回警告
+	SeverityOverrides map[string]Severity // 按检查 ID 覆盖默认严重程度
+	// ExemptNamespaces 是允许通过 getnopss.io/exempt 注解豁免检查的命名空间。注解由工作负载的作者自行添加，
+	// 其他命名空间中的注解豁免不生效，问题按严重程度照常拒绝或警告
+	ExemptNamespaces []NamespacePattern
+	// PinnedVersion 返回命名空间通过 enforce-version 标签固定的 Pod Security Standards 版本，
+	// 固定了版本的命名空间中 Checks 按该版本执行。为空表示不查询，所有请求按 Checks 绑定的版本执行
+	PinnedVersion func(namespace string) (PSSVersion, bool)
+}
+
+// checks 返回在命名空间中执行的检查
+func (p AdmissionPolicy) checks(namespace string) []Check {
+	if p.PinnedVersion == nil {
+		return p.Checks
+	}
+	if version, ok := p.PinnedVersion(namespace); ok {
+		return ChecksForVersion(p.Checks, version, nil)
+	}
+	return p.Checks
+}
+
+// exemptionsAllowed 判断命名空间中的注解豁免是否生效
+func (p AdmissionPolicy) exemptionsAllowed(namespace string) bool {
+	for _, pattern := range p.ExemptNamespaces {
+		if pattern.Match(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// AdmissionHandler 是校验型准入 Webhook 的 HTTP 处理器，对 Pod 和工作负载模板执行已注册的检查
+type AdmissionHandler struct {
+	policy AdmissionPolicy
+}
+
+func NewAdmissionHandler(policy AdmissionPolicy) *AdmissionHandler {
+	return &AdmissionHandler{policy: policy}
+}
+
+func (h *AdmissionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		http.Error(w, fmt.Sprintf("unsupported content type %q", ct), http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAdmissionBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.Unmarshal(body, &review); err != nil {
+		http.Error(w, fmt.Sprintf("decode AdmissionReview: %v", err), http.StatusBadRequest)
+		return
+	}
+	if review.Request == nil {
+		http.Error(w, "AdmissionReview has no request", http.StatusBadRequest)
+		return
+	}
+
+	review.Response = h.Review(review.Request)
+	review.Response.UID = review.Request.UID
+	review.Request = nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&review); err != nil {
+		log.Error().Err(err).Msg("写入 AdmissionReview 响应失败")
+	}
+}
+
+// Review 对单个准入请求执行检查并给出允许、警告或拒绝的结果
+func (h *AdmissionHandler) Review(req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	allowed := &admissionv1.AdmissionResponse{UID: req.UID, Allowed: true}
+	// 只检查对象本身和 ephemeralcontainers 子资源，DELETE 等没有对象的请求直接放行
+	if req.SubResource != "" && req.SubResource != "ephemeralcontainers" {
+		return allowed
+	}
+	if len(req.Object.Raw) == 0 {
+		return allowed
+	}
+
+	pod, ok, err := PodFromObject(req.Kind.Kind, req.Object.Raw)
+	if err != nil {
+		return &admissionv1.AdmissionResponse{
+			UID:     req.UID,
+			Allowed: false,
+			Result:  &metav1.Status{Status: metav1.StatusFailure, Code: http.StatusBadRequest, Message: fmt.Sprintf("decode %s: %v", req.Kind.Kind, err)},
+		}
+	}
+	if !ok {
+		return allowed
+	}
+	if pod.Namespace == "" {
+		pod.Namespace = req.Namespace
+	}
+	if pod.Name == "" {
+		pod.Name = req.Name
+	}
+	if pod.Name == "" {
+		pod.Name = pod.GenerateName
+	}
+
+	namespace := req.Namespace
+	if namespace == "" {
+		namespace = pod.Namespace
+	}
+	exemptionsAllowed := h.policy.exemptionsAllowed(namespace)
+
+	var denied, warnings []string
+	for _, f := range EvaluatePod(pod, h.policy.checks(namespace)) {
+		if sev, ok := h.policy.SeverityOverrides[f.Check]; ok {
+			f.Severity = sev
+		}
+		message := admissionMessage(f)
+		if f.Suppressed() && !exemptionsAllowed {
+			message += " (exemption annotation is not allowed in namespace " + namespace + ")"
+			f.Suppression = nil
+		}
+		// ExemptNamespaces 中通过注解豁免的问题不会拒绝请求，达到警告级别时仍然提示
+		switch {
+		case f.Suppressed():
+			if h.policy.WarnSeverity != "" && f.Severity.Rank() >= h.policy.WarnSeverity.Rank() {
+				warnings = append(warnings, message+" (exempted: "+exemptReason(f.Suppression)+")")
+			}
+		case h.policy.DenySeverity != "" && f.Severity.Rank() >= h.policy.DenySeverity.Rank():
+			denied = append(denied, message)
+		case h.policy.WarnSeverity != "" && f.Severity.Rank() >= h.policy.WarnSeverity.Rank():
+			warnings = append(warnings, message)
+		}
+	}
+
+	log.Info().
+		Str("operation", string(req.Operation)).
+		Str("kind", req.Kind.Kind).
+		Str("namespace", pod.Namespace).
+		Str("name", pod.Name).
+		Int("denied", len(denied)).
+		Int("warnings", len(warnings)).
+		Msg("准入检查完成")
+
+	if len(denied) > 0 {
+		return &admissionv1.AdmissionResponse{
+			UID:      req.UID,
+			Allowed:  false,
+			Warnings: warnings,
+			Result: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Code:    http.StatusForbidden,
+				Reason:  metav1.StatusReasonForbidden,
+				Message: fmt.Sprintf("%s %s violates pod security checks: %s", req.Kind.Kind, pod.Name, strings.Join(denied, "; ")),
+			},
+		}
+	}
+	allowed.Warnings = warnings
+	return allowed
+}
+
+// admissionMessage 把问题格式化为一行准入提示
+func admissionMessage(f Finding) string {
+	msg := fmt.Sprintf("[%s/%s]", f.Check, f.Severity)
+	if f.Container != "" {
+		msg += " container " + f.Container
+	}
+	if c, ok := LookupCheck(f.Check); ok {
+		msg += " " + c.Title()
+	}
+	if details := f.Details(); details != "" {
+		msg += ": " + details
+	}
+	return msg
+}
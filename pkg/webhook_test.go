@@ -0,0 +1,287 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// selfSignedCert 生成 127.0.0.1 的自签名证书，返回服务端证书和信任该证书的 CA 池
+func selfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "getnopss-webhook"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}, pool
+}
+
+// startWebhook 用自签名证书启动 TLS 准入服务，返回服务地址和只信任该证书的客户端
+func startWebhook(t *testing.T, policy AdmissionPolicy) (string, *http.Client) {
+	t.Helper()
+	cert, pool := selfSignedCert(t)
+	mux := http.NewServeMux()
+	mux.Handle("/validate", NewAdmissionHandler(policy))
+	server := httptest.NewUnstartedServer(mux)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	return server.URL + "/validate", client
+}
+
+func admissionReview(t *testing.T, kind, namespace string, obj runtime.Object) []byte {
+	t.Helper()
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := ""
+	if kind != "Pod" {
+		group = "apps"
+	}
+	review := admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request: &admissionv1.AdmissionRequest{
+			UID:       types.UID("req-" + strings.ToLower(kind)),
+			Kind:      metav1.GroupVersionKind{Group: group, Version: "v1", Kind: kind},
+			Namespace: namespace,
+			Operation: admissionv1.Create,
+			Object:    runtime.RawExtension{Raw: raw},
+		},
+	}
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func testPod(namespace string, annotations map[string]string, mutate func(spec *corev1.PodSpec)) *corev1.Pod {
+	pod := &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace, Annotations: annotations},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "nginx:1.25"}}},
+	}
+	if mutate != nil {
+		mutate(&pod.Spec)
+	}
+	return pod
+}
+
+func testDeployment(namespace string, mutate func(spec *corev1.PodSpec)) *appsv1.Deployment {
+	pod := testPod(namespace, nil, mutate)
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: namespace},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "web"}, Annotations: pod.Annotations},
+				Spec:       pod.Spec,
+			},
+		},
+	}
+}
+
+func withPrivileged(spec *corev1.PodSpec) {
+	spec.Containers[0].SecurityContext = &corev1.SecurityContext{Privileged: boolPtr(true)}
+}
+
+func withHostPort(spec *corev1.PodSpec) {
+	spec.Containers[0].Ports = []corev1.ContainerPort{{ContainerPort: 80, HostPort: 8080}}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func TestAdmissionWebhookTLS(t *testing.T) {
+	exemptAll := map[string]string{ExemptAnnotation: "*", ExemptReasonAnnotation: "needs host access"}
+	kubeSystem, err := ParseNamespacePattern("kube-system")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, client := startWebhook(t, AdmissionPolicy{
+		Checks:           ChecksForProfile(LevelBaseline),
+		DenySeverity:     SeverityHigh,
+		WarnSeverity:     SeverityLow,
+		ExemptNamespaces: []NamespacePattern{kubeSystem},
+	})
+
+	tests := []struct {
+		name        string
+		kind        string
+		namespace   string
+		obj         runtime.Object
+		allowed     bool
+		warnings    []string // 每条都必须出现在某个警告中
+		denyMessage []string // 每条都必须出现在拒绝消息中
+	}{
+		{name: "compliant pod", kind: "Pod", namespace: "team", obj: testPod("team", nil, nil), allowed: true},
+		{name: "pod warned", kind: "Pod", namespace: "team", obj: testPod("team", nil, withHostPort), allowed: true,
+			warnings: []string{"[hostports/MEDIUM]", "8080"}},
+		{name: "pod denied", kind: "Pod", namespace: "team", obj: testPod("team", nil, withPrivileged),
+			denyMessage: []string{"[privileged/CRITICAL] container app"}},
+		{name: "compliant deployment", kind: "Deployment", namespace: "team", obj: testDeployment("team", nil), allowed: true},
+		{name: "deployment warned", kind: "Deployment", namespace: "team", obj: testDeployment("team", withHostPort), allowed: true,
+			warnings: []string{"[hostports/MEDIUM]"}},
+		{name: "deployment denied", kind: "Deployment", namespace: "team", obj: testDeployment("team", withPrivileged),
+			denyMessage: []string{"Deployment web", "[privileged/CRITICAL]"}},
+		{name: "self-declared exemption denied", kind: "Pod", namespace: "team", obj: testPod("team", exemptAll, withPrivileged),
+			denyMessage: []string{"[privileged/CRITICAL]", "exemption annotation is not allowed in namespace team"}},
+		{name: "exemption in allowed namespace warns", kind: "Pod", namespace: "kube-system", obj: testPod("kube-system", exemptAll, withPrivileged), allowed: true,
+			warnings: []string{"[privileged/CRITICAL]", "exempted: needs host access"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Post(url, "application/json", bytes.NewReader(admissionReview(t, tt.kind, tt.namespace, tt.obj)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status %d, want 200", resp.StatusCode)
+			}
+			var review admissionv1.AdmissionReview
+			if err := json.NewDecoder(resp.Body).Decode(&review); err != nil {
+				t.Fatal(err)
+			}
+			got := review.Response
+			if got == nil {
+				t.Fatal("AdmissionReview has no response")
+			}
+			if want := types.UID("req-" + strings.ToLower(tt.kind)); got.UID != want {
+				t.Errorf("UID = %q, want %q", got.UID, want)
+			}
+			if got.Allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v (result: %+v)", got.Allowed, tt.allowed, got.Result)
+			}
+			warnings := strings.Join(got.Warnings, "\n")
+			for _, want := range tt.warnings {
+				if !strings.Contains(warnings, want) {
+					t.Errorf("warnings %q do not contain %q", got.Warnings, want)
+				}
+			}
+			if len(tt.warnings) == 0 && tt.allowed && len(got.Warnings) > 0 {
+				t.Errorf("unexpected warnings %q", got.Warnings)
+			}
+			if tt.allowed {
+				return
+			}
+			if got.Result == nil || got.Result.Code != http.StatusForbidden {
+				t.Fatalf("result = %+v, want 403", got.Result)
+			}
+			for _, want := range tt.denyMessage {
+				if !strings.Contains(got.Result.Message, want) {
+					t.Errorf("message %q does not contain %q", got.Result.Message, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAdmissionWebhookRejectsBadRequests(t *testing.T) {
+	url, client := startWebhook(t, AdmissionPolicy{Checks: ChecksForProfile(LevelBaseline)})
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET status %d, want 405", resp.StatusCode)
+	}
+
+	resp, err = client.Post(url, "application/json", strings.NewReader(`{"apiVersion":"admission.k8s.io/v1","kind":"AdmissionReview"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("review without request status %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestAdmissionPinnedVersion(t *testing.T) {
+	pinned := map[string]PSSVersion{"legacy": {Minor: 27}, "current": {Minor: 29}}
+	policy := AdmissionPolicy{
+		Checks:       ChecksForVersion(ChecksForProfile(LevelBaseline), LatestPSSVersion, nil),
+		DenySeverity: SeverityMedium,
+		PinnedVersion: func(namespace string) (PSSVersion, bool) {
+			v, ok := pinned[namespace]
+			return v, ok
+		},
+	}
+	handler := NewAdmissionHandler(policy)
+	// net.ipv4.tcp_keepalive_time 从 v1.29 起是安全的 sysctl
+	pod := testPod("", nil, func(spec *corev1.PodSpec) {
+		spec.SecurityContext = &corev1.PodSecurityContext{Sysctls: []corev1.Sysctl{{Name: "net.ipv4.tcp_keepalive_time", Value: "600"}}}
+	})
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		namespace string
+		allowed   bool
+	}{
+		{namespace: "legacy", allowed: false},
+		{namespace: "current", allowed: true},
+		{namespace: "default", allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.namespace, func(t *testing.T) {
+			resp := handler.Review(&admissionv1.AdmissionRequest{
+				UID:       types.UID("uid-" + tt.namespace),
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				Namespace: tt.namespace,
+				Operation: admissionv1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			})
+			if resp.Allowed != tt.allowed {
+				t.Errorf("allowed = %v, want %v (result %+v)", resp.Allowed, tt.allowed, resp.Result)
+			}
+		})
+	}
+}
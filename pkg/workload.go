@@ -0,0 +1,140 @@
+package pkg
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Workload 是集群中一个顶层控制器(或独立 Pod)的 Pod 模板
+type Workload struct {
+	Kind     string
+	Replicas int
+	Pod      corev1.Pod
+}
+
+// ListWorkloads 获取扫描范围内所有顶层控制器的 Pod 模板，以及不属于这些控制器的 Pod。
+// 被 Deployment 管理的 ReplicaSet、被 CronJob 创建的 Job 不会重复列出
+func ListWorkloads(clientset kubernetes.Interface, scope *Scope) ([]Workload, error) {
+	ctx := context.TODO()
+	namespace := scope.ListNamespace()
+	inScope, err := scope.NamespaceMatcher(ctx, clientset)
+	if err != nil {
+		return nil, err
+	}
+	var workloads []Workload
+	add := func(kind string, meta metav1.ObjectMeta, replicas int, template corev1.PodTemplateSpec) {
+		// Pod 标签选择器作用于模板中的标签，这样与扫描 Pod 时的范围一致
+		if !inScope(meta.Namespace) || !scope.MatchLabels(template.Labels) || listedController(&meta) {
+			return
+		}
+		workloads = append(workloads, Workload{Kind: kind, Replicas: replicas, Pod: *templatePod(meta, template)})
+	}
+
+	deployments, err := clientset.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deployments.Items {
+		add("Deployment", d.ObjectMeta, replicaCount(d.Spec.Replicas), d.Spec.Template)
+	}
+
+	statefulSets, err := clientset.AppsV1().StatefulSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range statefulSets.Items {
+		add("StatefulSet", s.ObjectMeta, replicaCount(s.Spec.Replicas), s.Spec.Template)
+	}
+
+	daemonSets, err := clientset.AppsV1().DaemonSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range daemonSets.Items {
+		add("DaemonSet", d.ObjectMeta, int(d.Status.DesiredNumberScheduled), d.Spec.Template)
+	}
+
+	replicaSets, err := clientset.AppsV1().ReplicaSets(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range replicaSets.Items {
+		add("ReplicaSet", r.ObjectMeta, replicaCount(r.Spec.Replicas), r.Spec.Template)
+	}
+
+	cronJobs, err := clientset.BatchV1().CronJobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range cronJobs.Items {
+		add("CronJob", c.ObjectMeta, replicaCount(c.Spec.JobTemplate.Spec.Parallelism), c.Spec.JobTemplate.Spec.Template)
+	}
+
+	jobs, err := clientset.BatchV1().Jobs(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range jobs.Items {
+		add("Job", j.ObjectMeta, replicaCount(j.Spec.Parallelism), j.Spec.Template)
+	}
+
+	// 只保留不属于控制器的 Pod，分页获取避免一次加载集群中的全部 Pod
+	err = ForEachPodPage(ctx, clientset, scope, func(pods []corev1.Pod) error {
+		for i := range pods {
+			if !listedController(&pods[i]) {
+				workloads = append(workloads, Workload{Kind: "Pod", Replicas: 1, Pod: pods[i]})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return workloads, nil
+}
+
+// listedController 判断对象的控制器是否已经作为工作负载被列出，例如 Deployment 管理的 ReplicaSet。
+// 由其他控制器(如 CRD operator)管理的对象仍然单独检查
+func listedController(obj metav1.Object) bool {
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil {
+		return false
+	}
+	switch ref.Kind {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet", "Job", "CronJob":
+		return true
+	}
+	return false
+}
+
+// replicaCount 返回副本数，未设置时按 Kubernetes 默认值 1 计算
+func replicaCount(replicas *int32) int {
+	if replicas == nil {
+		return 1
+	}
+	return int(*replicas)
+}
+
+// ScanWorkloads 直接检查工作负载的 Pod 模板，每个工作负载的问题只报告一次
+func ScanWorkloads(workloads []Workload, checks []Check) ScanResult {
+	result := ScanResult{Checks: checks}
+	for i := range workloads {
+		w := &workloads[i]
+		result.Objects = append(result.Objects, ScannedObject{
+			Namespace: w.Pod.Namespace, Kind: w.Kind, Name: w.Pod.Name, Pods: w.Replicas, Containers: podContainers(&w.Pod),
+		})
+		result.Pods++
+		for _, f := range EvaluatePod(&w.Pod, checks) {
+			f.Kind = w.Kind
+			f.OwnerKind = w.Kind
+			f.OwnerName = w.Pod.Name
+			f.Replicas = w.Replicas
+			result.Findings = append(result.Findings, f)
+		}
+	}
+	return result
+}